@@ -0,0 +1,238 @@
+package firebase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultBatchConcurrency is the default maximum number of in-flight
+	// requests for batch operations.
+	DefaultBatchConcurrency = 8
+)
+
+// batchConfig holds the configuration of a batch operation.
+type batchConfig struct {
+	concurrency int
+	failFast    bool
+	queryOpts   []QueryOption
+}
+
+// BatchOption is an option to modify a batch operation.
+type BatchOption func(b *batchConfig) error
+
+// BatchConcurrency is a batch option that sets the maximum number of
+// in-flight requests for a batch operation.
+func BatchConcurrency(n int) BatchOption {
+	return func(b *batchConfig) error {
+		if n < 1 {
+			return fmt.Errorf("batch concurrency must be greater than 0, got: %d", n)
+		}
+
+		b.concurrency = n
+		return nil
+	}
+}
+
+// BatchFailFast is a batch option that stops a batch operation after the
+// first failed item. Items that were not processed are reported as failed
+// with context.Canceled.
+func BatchFailFast(b *batchConfig) error {
+	b.failFast = true
+	return nil
+}
+
+// BatchQueryOptions is a batch option that sets the query options passed to
+// each request made by a batch operation.
+func BatchQueryOptions(opts ...QueryOption) BatchOption {
+	return func(b *batchConfig) error {
+		b.queryOpts = opts
+		return nil
+	}
+}
+
+// BatchError is the error returned when one or more items of a batch
+// operation failed.
+type BatchError struct {
+	// Errors are the errors of the failed items, keyed by the item's index.
+	Errors map[int]error
+}
+
+// Error satisfies the error interface.
+func (e *BatchError) Error() string {
+	indexes := e.Indexes()
+
+	errs := make([]string, len(indexes))
+	for i, n := range indexes {
+		errs[i] = fmt.Sprintf("%d: %v", n, e.Errors[n])
+	}
+
+	return fmt.Sprintf("firebase: %d batch item(s) failed: %s", len(indexes), strings.Join(errs, "; "))
+}
+
+// Indexes returns the sorted indexes of the failed items.
+func (e *BatchError) Indexes() []int {
+	indexes := make([]int, 0, len(e.Errors))
+	for i := range e.Errors {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	return indexes
+}
+
+// Unwrap returns the errors of the failed items.
+func (e *BatchError) Unwrap() []error {
+	indexes := e.Indexes()
+
+	errs := make([]error, len(indexes))
+	for i, n := range indexes {
+		errs[i] = e.Errors[n]
+	}
+
+	return errs
+}
+
+// batch runs fn for each of the n items of a batch operation with opts,
+// returning a *BatchError if any of the items failed.
+func batch(ctxt context.Context, n int, opts []BatchOption, fn func(context.Context, int, []QueryOption) error) error {
+	var err error
+
+	// apply opts
+	b := &batchConfig{
+		concurrency: DefaultBatchConcurrency,
+	}
+	for _, o := range opts {
+		err = o(b)
+		if err != nil {
+			return err
+		}
+	}
+
+	ctxt, cancel := context.WithCancel(ctxt)
+	defer cancel()
+
+	errs := make([]error, n)
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, b.concurrency)
+	for i := 0; i < n; i++ {
+		// wait for slot
+		select {
+		case sem <- struct{}{}:
+		case <-ctxt.Done():
+		}
+
+		// mark unprocessed items as failed
+		if ctxt.Err() != nil {
+			for ; i < n; i++ {
+				errs[i] = ctxt.Err()
+			}
+			break
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			errs[i] = fn(ctxt, i, b.queryOpts)
+			if errs[i] != nil && b.failFast {
+				cancel()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// collect errors
+	e := &BatchError{
+		Errors: make(map[int]error),
+	}
+	for i, err := range errs {
+		if err != nil {
+			e.Errors[i] = err
+		}
+	}
+	if len(e.Errors) != 0 {
+		return e
+	}
+
+	return nil
+}
+
+// PushAll concurrently pushes each of values to Firebase database ref r,
+// returning the names (IDs) of the pushed nodes, such that names[i] is the
+// name of the node pushed for values[i].
+//
+// By default, a failed push does not stop the remaining values from being
+// pushed. When any of the pushes fail, a *BatchError identifying the failed
+// values is returned along with the names of the successfully pushed nodes.
+func PushAll(r *DatabaseRef, values []interface{}, opts ...BatchOption) ([]string, error) {
+	return PushAllContext(context.Background(), r, values, opts...)
+}
+
+// PushAllContext concurrently pushes each of values to Firebase database ref
+// r, returning the names (IDs) of the pushed nodes, using the provided
+// context.
+func PushAllContext(ctxt context.Context, r *DatabaseRef, values []interface{}, opts ...BatchOption) ([]string, error) {
+	names := make([]string, len(values))
+	err := batch(ctxt, len(values), opts, func(ctxt context.Context, i int, queryOpts []QueryOption) error {
+		var err error
+		names[i], err = PushContext(ctxt, r, values[i], queryOpts...)
+		return err
+	})
+
+	var e *BatchError
+	if err != nil && !errors.As(err, &e) {
+		return nil, err
+	}
+
+	return names, err
+}
+
+// PushAll concurrently pushes each of values to the Firebase database ref,
+// returning the names (IDs) of the pushed nodes.
+func (r *DatabaseRef) PushAll(values []interface{}, opts ...BatchOption) ([]string, error) {
+	return PushAll(r, values, opts...)
+}
+
+// PushAllContext concurrently pushes each of values to the Firebase database
+// ref, returning the names (IDs) of the pushed nodes, using the provided
+// context.
+func (r *DatabaseRef) PushAllContext(ctxt context.Context, values []interface{}, opts ...BatchOption) ([]string, error) {
+	return PushAllContext(ctxt, r, values, opts...)
+}
+
+// GetAll concurrently retrieves the values stored at each of refs, decoding
+// them into the corresponding dests, such that the value stored at refs[i] is
+// decoded into dests[i].
+//
+// A failed retrieval does not stop the remaining refs from being retrieved.
+// When any of the retrievals fail, a *BatchError identifying the failed refs
+// (by index) is returned.
+func GetAll(refs []*DatabaseRef, dests []interface{}, opts ...BatchOption) error {
+	return GetAllContext(context.Background(), refs, dests, opts...)
+}
+
+// GetAllContext concurrently retrieves the values stored at each of refs,
+// decoding them into the corresponding dests, using the provided context.
+//
+// When the context is done, in-flight requests are canceled, and the
+// remaining refs are reported as failed with the context's error.
+func GetAllContext(ctxt context.Context, refs []*DatabaseRef, dests []interface{}, opts ...BatchOption) error {
+	if len(refs) != len(dests) {
+		return &Error{
+			Err: fmt.Sprintf("refs and dests must have the same length, got: %d and %d", len(refs), len(dests)),
+		}
+	}
+
+	return batch(ctxt, len(refs), opts, func(ctxt context.Context, i int, queryOpts []QueryOption) error {
+		return GetContext(ctxt, refs[i], dests[i], queryOpts...)
+	})
+}
@@ -0,0 +1,151 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPushAll(t *testing.T) {
+	var inflight, max int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n := atomic.AddInt32(&inflight, 1)
+		defer atomic.AddInt32(&inflight, -1)
+		for {
+			m := atomic.LoadInt32(&max)
+			if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		var v int
+		json.NewDecoder(req.Body).Decode(&v)
+		if v%5 == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"bad value"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"name": strconv.Itoa(v)})
+	}))
+	defer srv.Close()
+
+	values := make([]interface{}, 20)
+	for i := range values {
+		values[i] = i + 1
+	}
+
+	names, err := newTestRef(t, srv).PushAll(values, BatchConcurrency(4))
+	var e *BatchError
+	if !errors.As(err, &e) {
+		t.Fatalf("expected *BatchError, got: %v", err)
+	}
+
+	exp := []int{4, 9, 14, 19}
+	indexes := e.Indexes()
+	if len(indexes) != len(exp) {
+		t.Fatalf("expected failed indexes %v, got: %v", exp, indexes)
+	}
+	for i, n := range exp {
+		if indexes[i] != n {
+			t.Errorf("expected failed indexes %v, got: %v", exp, indexes)
+		}
+	}
+
+	for i, name := range names {
+		if _, failed := e.Errors[i]; failed {
+			if name != "" {
+				t.Errorf("expected empty name for failed item %d, got: %q", i, name)
+			}
+			continue
+		}
+		if exp := strconv.Itoa(values[i].(int)); name != exp {
+			t.Errorf("expected name %s for item %d, got: %q", exp, i, name)
+		}
+	}
+
+	if m := atomic.LoadInt32(&max); m > 4 {
+		t.Errorf("expected at most 4 in-flight requests, got: %d", m)
+	}
+}
+
+func TestPushAllFailFast(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"bad value"}`))
+	}))
+	defer srv.Close()
+
+	values := make([]interface{}, 100)
+	_, err := newTestRef(t, srv).PushAll(values, BatchConcurrency(1), BatchFailFast)
+	var e *BatchError
+	if !errors.As(err, &e) {
+		t.Fatalf("expected *BatchError, got: %v", err)
+	}
+	if len(e.Errors) != len(values) {
+		t.Errorf("expected all items to be reported as failed, got: %d", len(e.Errors))
+	}
+	if !errors.Is(e.Errors[len(values)-1], context.Canceled) {
+		t.Errorf("expected unprocessed item to be canceled, got: %v", e.Errors[len(values)-1])
+	}
+}
+
+func TestGetAll(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, "/"), ".json"))
+		if n == 7 {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"Permission denied"}`))
+			return
+		}
+		// complete out of order
+		time.Sleep(time.Duration(20-n) * time.Millisecond)
+		w.Write([]byte(strconv.Itoa(n * 10)))
+	}))
+	defer srv.Close()
+
+	r := newTestRef(t, srv)
+	refs := make([]*DatabaseRef, 20)
+	vals := make([]int, len(refs))
+	dests := make([]interface{}, len(refs))
+	for i := range refs {
+		refs[i], dests[i] = r.Ref(strconv.Itoa(i)), &vals[i]
+	}
+
+	err := GetAll(refs, dests, BatchConcurrency(5))
+	var e *BatchError
+	if !errors.As(err, &e) {
+		t.Fatalf("expected *BatchError, got: %v", err)
+	}
+	if indexes := e.Indexes(); len(indexes) != 1 || indexes[0] != 7 || ErrorPath(e.Errors[7]) != "/7" {
+		t.Errorf("expected ref 7 to fail, got: %v", err)
+	}
+	for i, v := range vals {
+		if i != 7 && v != i*10 {
+			t.Errorf("expected value %d for ref %d, got: %d", i*10, i, v)
+		}
+	}
+
+	// canceled
+	ctxt, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = GetAllContext(ctxt, refs, dests, BatchConcurrency(1))
+	if !errors.As(err, &e) || !errors.Is(e.Errors[len(refs)-1], context.DeadlineExceeded) {
+		t.Errorf("expected pending refs to fail with deadline exceeded, got: %v", err)
+	}
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Errorf("expected canceled requests to stop promptly, took: %s", d)
+	}
+
+	if err := GetAll(refs, dests[:1]); err == nil {
+		t.Errorf("expected error")
+	}
+}
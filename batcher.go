@@ -0,0 +1,191 @@
+package firebase
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Batcher coalesces writes to paths relative to a Firebase database ref,
+// periodically flushing the queued writes as a single multi-path update (see
+// UpdateBuilder).
+//
+// Later writes to the same path replace earlier queued writes, and a write to
+// a path replaces any queued writes to the path's children. A write to a
+// child of a queued path first flushes the queued writes, so that the write is
+// not lost.
+//
+// A Batcher is safe for concurrent use.
+type Batcher struct {
+	r       *DatabaseRef
+	maxOps  int
+	onError func(error)
+
+	// flushMu serializes flushes, so that writes are sent in order.
+	flushMu sync.Mutex
+
+	mu     sync.Mutex
+	paths  map[string]interface{}
+	closed bool
+
+	full chan struct{}
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewBatcher creates a batcher for the Firebase database ref r, flushing the
+// queued writes every flushInterval, or once maxOps writes have been queued.
+// Errors from background flushes are passed to onError (when not nil).
+//
+// A flushInterval or maxOps less than 1 disables flushing on the respective
+// condition. Close must be called to flush the remaining writes and to stop
+// the batcher.
+func NewBatcher(r *DatabaseRef, flushInterval time.Duration, maxOps int, onError func(error)) *Batcher {
+	b := &Batcher{
+		r:       r,
+		maxOps:  maxOps,
+		onError: onError,
+		paths:   make(map[string]interface{}),
+		full:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+
+	b.wg.Add(1)
+	go b.run(flushInterval)
+
+	return b
+}
+
+// run flushes the queued writes every interval, or when the batcher is full,
+// until the batcher is closed.
+func (b *Batcher) run(interval time.Duration) {
+	defer b.wg.Done()
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		tick = t.C
+	}
+
+	for {
+		select {
+		case <-tick:
+		case <-b.full:
+		case <-b.done:
+			return
+		}
+
+		if err := b.Flush(); err != nil && b.onError != nil {
+			b.onError(err)
+		}
+	}
+}
+
+// Set queues the write of value v to path, relative to the batcher's ref.
+func (b *Batcher) Set(path string, v interface{}) error {
+	key, err := new(UpdateBuilder).key(path)
+	if err != nil {
+		return err
+	}
+
+	for {
+		b.mu.Lock()
+		if b.closed {
+			b.mu.Unlock()
+			return &Error{Err: "batcher is closed"}
+		}
+
+		// flush when a parent of path is queued
+		var parent bool
+		for k := range b.paths {
+			if strings.HasPrefix(key, k+"/") {
+				parent = true
+				break
+			}
+		}
+		if parent {
+			b.mu.Unlock()
+			if err := b.Flush(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// replace queued children of path
+		for k := range b.paths {
+			if strings.HasPrefix(k, key+"/") {
+				delete(b.paths, k)
+			}
+		}
+		b.paths[key] = v
+		full := b.maxOps > 0 && len(b.paths) >= b.maxOps
+		b.mu.Unlock()
+
+		if full {
+			select {
+			case b.full <- struct{}{}:
+			default:
+			}
+		}
+
+		return nil
+	}
+}
+
+// Delete queues the removal of the value at path (ie, setting the value to
+// null), relative to the batcher's ref.
+func (b *Batcher) Delete(path string) error {
+	return b.Set(path, nil)
+}
+
+// Len returns the number of queued writes.
+func (b *Batcher) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.paths)
+}
+
+// Flush synchronously sends the queued writes as a single multi-path update.
+// No request is made when no writes are queued. The writes of a failed flush
+// are discarded.
+func (b *Batcher) Flush() error {
+	return b.FlushContext(context.Background())
+}
+
+// FlushContext synchronously sends the queued writes as a single multi-path
+// update, using the provided context.
+func (b *Batcher) FlushContext(ctxt context.Context) error {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	b.mu.Lock()
+	paths := b.paths
+	b.paths = make(map[string]interface{})
+	b.mu.Unlock()
+
+	if len(paths) == 0 {
+		return nil
+	}
+
+	return UpdateContext(ctxt, b.r, paths)
+}
+
+// Close stops the batcher, synchronously flushing the remaining queued
+// writes. Writes queued after Close return an error.
+func (b *Batcher) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	b.mu.Unlock()
+
+	close(b.done)
+	b.wg.Wait()
+
+	return b.Flush()
+}
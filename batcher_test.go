@@ -0,0 +1,84 @@
+package firebase
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBatcher(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(req.Body).Decode(&body)
+		mu.Lock()
+		bodies = append(bodies, body)
+		mu.Unlock()
+		if _, ok := body["fail"]; ok {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid"}`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	errs := make(chan error, 1)
+	b := NewBatcher(newTestRef(t, srv), time.Hour, 3, func(err error) {
+		errs <- err
+	})
+
+	// coalesced writes
+	b.Set("a/b", 1)
+	b.Set("a/c", 2)
+	b.Set("a/b", 3)
+	b.Set("a", "parent")
+	b.Delete("d")
+	if err := b.Flush(); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// a write to a child of a queued path flushes first
+	b.Set("x", map[string]int{"y": 1})
+	b.Set("x/y", 2)
+
+	// max ops flushes in the background, and reports errors
+	b.Set("fail", 1)
+	b.Set("z", 1)
+	select {
+	case err := <-errs:
+		var e *Error
+		if !errors.As(err, &e) || e.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected bad request, got: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected background flush")
+	}
+
+	b.Set("last", true)
+	if err := b.Close(); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := b.Set("after", 1); err == nil {
+		t.Errorf("expected error after close")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	exp := []map[string]interface{}{
+		{"a": "parent", "d": nil},
+		{"x": map[string]interface{}{"y": float64(1)}},
+		{"x/y": float64(2), "fail": float64(1), "z": float64(1)},
+		{"last": true},
+	}
+	if !reflect.DeepEqual(bodies, exp) {
+		t.Errorf("expected %v, got: %v", exp, bodies)
+	}
+}
@@ -0,0 +1,167 @@
+package firebase
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// readCache is a bounded (LRU) cache of the ETags and raw values of reads
+// made with a database ref (see the Cache option).
+type readCache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+// cacheEntry is a cached read.
+type cacheEntry struct {
+	key     string
+	path    string
+	etag    string
+	data    []byte
+	expires time.Time
+}
+
+// newReadCache creates a read cache.
+func newReadCache(ttl time.Duration, maxEntries int) *readCache {
+	return &readCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// lookup returns the unexpired entry for key, if any.
+func (c *readCache) lookup(key string) *cacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	e := el.Value.(*cacheEntry)
+	if time.Now().After(e.expires) {
+		c.remove(el)
+		return nil
+	}
+	c.lru.MoveToFront(el)
+
+	return e
+}
+
+// store stores the entry for key, evicting the least recently used entry when
+// the cache is full.
+func (c *readCache) store(key, path, etag string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := &cacheEntry{
+		key:     key,
+		path:    path,
+		etag:    etag,
+		data:    data,
+		expires: time.Now().Add(c.ttl),
+	}
+	if el, ok := c.entries[key]; ok {
+		el.Value = e
+		c.lru.MoveToFront(el)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(e)
+	for c.lru.Len() > c.maxEntries {
+		c.remove(c.lru.Back())
+	}
+}
+
+// invalidate removes the entries for path, and for its parents and children.
+func (c *readCache) invalidate(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	path = strings.TrimSuffix(path, "/")
+	for _, el := range c.entries {
+		p := strings.TrimSuffix(el.Value.(*cacheEntry).path, "/")
+		if p == path || strings.HasPrefix(path, p+"/") || strings.HasPrefix(p, path+"/") {
+			c.remove(el)
+		}
+	}
+}
+
+// remove removes the entry el.
+func (c *readCache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*cacheEntry).key)
+}
+
+// get retrieves the values stored at Firebase database ref r with a
+// conditional request, serving the cached value when the value has not
+// changed, and decodes the value into d. Returns false when the read cannot
+// be cached (ie, for filtered queries).
+func (c *readCache) get(ctxt context.Context, r *DatabaseRef, d interface{}, opts []QueryOption) (*http.Response, bool, error) {
+	q, err := r.buildQuery(opts...)
+	if err != nil {
+		return nil, true, &Error{
+			Err: fmt.Sprintf("could not create request: %v", err),
+		}
+	}
+
+	// firebase does not return ETags for queries
+	if len(q.Header) != 0 || q.Values.Get("orderBy") != "" || q.Values.Get("shallow") != "" {
+		return nil, false, nil
+	}
+
+	path := r.URL().Path
+	key := path + "?" + q.Values.Encode()
+
+	// conditional request
+	opts = append(opts[:len(opts):len(opts)], etagQuery)
+	e := c.lookup(key)
+	if e != nil {
+		opts = append(opts, ifNoneMatchQuery(e.etag))
+	}
+	var buf bytes.Buffer
+	res, err := execRequest(ctxt, OpTypeGet, r, nil, &buf, opts...)
+	if err != nil {
+		return res, true, err
+	}
+
+	data := buf.Bytes()
+	switch {
+	case res.StatusCode == http.StatusNotModified && e != nil:
+		data = e.data
+	case etagOf(res) != "":
+		c.store(key, path, etagOf(res), data)
+	}
+
+	// decode
+	if d == nil || len(data) == 0 {
+		return res, true, nil
+	}
+	if w, ok := d.(io.Writer); ok {
+		if _, err := w.Write(data); err != nil {
+			return nil, true, &Error{
+				Err: fmt.Sprintf("could not read body: %v", err),
+				err: err,
+			}
+		}
+		return res, true, nil
+	}
+	if err := r.decoder(opts...).decode(data, d); err != nil {
+		return nil, true, err
+	}
+
+	return res, true, nil
+}
@@ -0,0 +1,108 @@
+package firebase
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	var mu sync.Mutex
+	var conditional, notModified int
+	values := map[string]string{"/a": `{"b":1}`, "/c": `"c"`}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		path := strings.TrimSuffix(req.URL.Path, ".json")
+		if req.Method != "GET" {
+			// writes change the top-level node
+			values["/"+strings.Split(path, "/")[1]] = `"changed"`
+			w.Write([]byte(`{}`))
+			return
+		}
+		if req.Header.Get("X-Firebase-ETag") != "true" {
+			w.Write([]byte(values[path]))
+			return
+		}
+
+		etag := "etag-" + values[path]
+		w.Header().Set("ETag", etag)
+		if match := req.Header.Get("If-None-Match"); match != "" {
+			conditional++
+			if match == etag {
+				notModified++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		w.Write([]byte(values[path]))
+	}))
+	defer srv.Close()
+
+	r := newTestRef(t, srv, Cache(time.Minute, 1))
+	get := func(path string, exp string) {
+		var v interface{}
+		var buf strings.Builder
+		if err := r.Ref(path).Get(&buf); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if err := r.Ref(path).Get(&v); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if buf.String() != exp {
+			t.Errorf("expected %s, got: %s", exp, buf.String())
+		}
+	}
+	counts := func(expConditional, expNotModified int) {
+		mu.Lock()
+		defer mu.Unlock()
+		if conditional != expConditional || notModified != expNotModified {
+			t.Errorf("expected %d conditional and %d not modified requests, got: %d, %d", expConditional, expNotModified, conditional, notModified)
+		}
+	}
+
+	// first get populates the cache, second get is not modified
+	get("/a", `{"b":1}`)
+	counts(1, 1)
+
+	// write to a child invalidates the cached parent
+	if err := r.Ref("/a/b").Set(2); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	get("/a", `"changed"`)
+	counts(2, 2)
+
+	// lru eviction
+	get("/c", `"c"`)
+	get("/a", `"changed"`)
+	counts(4, 4)
+
+	// filtered queries are not cached
+	var v interface{}
+	if err := r.Ref("/a").Get(&v, Shallow); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	counts(4, 4)
+
+	// concurrent use
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var v interface{}
+			if err := r.Ref("/a").Get(&v); err != nil {
+				t.Errorf("expected no error, got: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := Cache(0, 1)(r); err == nil {
+		t.Errorf("expected error")
+	}
+}
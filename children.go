@@ -0,0 +1,428 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// ChildIterator iterates over the children of a Firebase database ref in key
+// order, retrieving the children a page at a time.
+//
+// A ChildIterator makes no requests until Next is first called.
+type ChildIterator struct {
+	ctxt     context.Context
+	r        *DatabaseRef
+	pageSize int
+
+	page   []KeyedValue
+	cursor string
+	done   bool
+	err    error
+
+	kv KeyedValue
+}
+
+// Children creates an iterator over the children stored at Firebase
+// database ref r, retrieving pageSize children per request.
+//
+// Children are retrieved in key order (ie, orderBy="$key") with each page
+// starting after the last key of the previous page, so each child is yielded
+// exactly once, including children added after the iterator has passed their
+// position.
+func Children(r *DatabaseRef, pageSize int) *ChildIterator {
+	return ChildrenContext(context.Background(), r, pageSize)
+}
+
+// ChildrenContext creates an iterator over the children stored at Firebase
+// database ref r, retrieving pageSize children per request using the
+// provided context.
+func ChildrenContext(ctxt context.Context, r *DatabaseRef, pageSize int) *ChildIterator {
+	return &ChildIterator{
+		ctxt:     ctxt,
+		r:        r,
+		pageSize: pageSize,
+	}
+}
+
+// Next advances the iterator to the next child, retrieving the next page of
+// children when needed. Next returns false when there are no more children,
+// or when an error was encountered (see Err).
+func (it *ChildIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	if len(it.page) == 0 {
+		if it.done {
+			return false
+		}
+		if it.err = it.fetch(); it.err != nil || len(it.page) == 0 {
+			return false
+		}
+	}
+
+	it.kv, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+// fetch retrieves the next page of children.
+func (it *ChildIterator) fetch() error {
+	if it.pageSize < 1 {
+		return &Error{
+			Err: fmt.Sprintf("page size must be greater than 0, got: %d", it.pageSize),
+		}
+	}
+
+	opts := []QueryOption{OrderBy("$key"), LimitToFirst(it.pageSize)}
+	if it.cursor != "" {
+		opts = append(opts, StartAfter(it.cursor))
+	}
+
+	page, err := GetOrderedContext(it.ctxt, it.r, opts...)
+	if err != nil {
+		return err
+	}
+	sortKeyedValues(page)
+
+	if len(page) < it.pageSize {
+		it.done = true
+	}
+	if len(page) != 0 {
+		it.cursor = page[len(page)-1].Key
+	}
+	it.page = page
+
+	return nil
+}
+
+// Key returns the key of the current child.
+func (it *ChildIterator) Key() string {
+	return it.kv.Key
+}
+
+// Value returns the JSON-encoded value of the current child.
+func (it *ChildIterator) Value() json.RawMessage {
+	return it.kv.Value
+}
+
+// Decode decodes the value of the current child into d.
+func (it *ChildIterator) Decode(d interface{}) error {
+	return it.r.decoder().decode(it.kv.Value, d)
+}
+
+// Err returns the error, if any, encountered during iteration.
+func (it *ChildIterator) Err() error {
+	return it.err
+}
+
+// Children creates an iterator over the children stored at the Firebase
+// database ref, retrieving pageSize children per request.
+func (r *DatabaseRef) Children(pageSize int) *ChildIterator {
+	return Children(r, pageSize)
+}
+
+// sortKeyedValues sorts kvs by key in Firebase key order.
+func sortKeyedValues(kvs []KeyedValue) {
+	sort.SliceStable(kvs, func(i, j int) bool {
+		return keyLess(kvs[i].Key, kvs[j].Key)
+	})
+}
+
+// keyLess returns true when key a sorts before key b in Firebase key order
+// (ie, keys that are 32-bit integers sort numerically before all other keys,
+// which sort lexicographically).
+func keyLess(a, b string) bool {
+	ai, aok := intKey(a)
+	bi, bok := intKey(b)
+	switch {
+	case aok && bok:
+		return ai < bi
+	case aok != bok:
+		return aok
+	}
+	return a < b
+}
+
+// intKey returns the integer value of key, and whether or not key is a
+// 32-bit integer key.
+func intKey(key string) (int64, bool) {
+	i, err := strconv.ParseInt(key, 10, 64)
+	if err != nil || i < math.MinInt32 || i > math.MaxInt32 || strconv.FormatInt(i, 10) != key {
+		return 0, false
+	}
+	return i, true
+}
+
+// GetPage retrieves a page of up to pageSize children stored at Firebase
+// database ref r, starting after cursor, returning the cursor for the next
+// page. An empty cursor retrieves the first page, and an empty next cursor is
+// returned when there are no more pages.
+//
+// Children are ordered by key, unless an OrderBy query option is passed (ie,
+// OrderBy("age") or OrderBy("$value")), in which case the children are ordered
+// by the specified value, and then by key. When ordering by key, the cursor is
+// the last key of the previous page. Otherwise, the cursor is an opaque value
+// encoding the last child's ordered value and key. Ordering by priority is not
+// supported.
+//
+// pageSize+1 children are requested from the server, so that the existence of
+// a next page is known without an additional request.
+func GetPage(r *DatabaseRef, pageSize int, cursor string, opts ...QueryOption) ([]KeyedValue, string, error) {
+	return GetPageContext(context.Background(), r, pageSize, cursor, opts...)
+}
+
+// GetPageContext retrieves a page of up to pageSize children stored at
+// Firebase database ref r, starting after cursor, returning the cursor for
+// the next page, using the provided context.
+func GetPageContext(ctxt context.Context, r *DatabaseRef, pageSize int, cursor string, opts ...QueryOption) ([]KeyedValue, string, error) {
+	if pageSize < 1 {
+		return nil, "", &Error{
+			Err: fmt.Sprintf("page size must be greater than 0, got: %d", pageSize),
+		}
+	}
+
+	// determine order
+	q, err := r.buildQuery(opts...)
+	if err != nil {
+		return nil, "", &Error{
+			Err: fmt.Sprintf("could not create request: %v", err),
+		}
+	}
+	var orderBy string
+	if ob := q.Values.Get("orderBy"); ob != "" {
+		if err = json.Unmarshal([]byte(ob), &orderBy); err != nil {
+			return nil, "", &Error{
+				Err: fmt.Sprintf("invalid orderBy %s", ob),
+			}
+		}
+	} else {
+		orderBy = "$key"
+		opts = append(opts, OrderBy(orderBy))
+	}
+	if orderBy == "$priority" {
+		return nil, "", &Error{
+			Err: "cannot paginate when ordering by priority",
+		}
+	}
+
+	// request page
+	opts = append(opts, LimitToFirst(pageSize+1))
+	if cursor != "" {
+		opts = append(opts, pageCursor(orderBy, cursor))
+	}
+	items, err := GetOrderedContext(ctxt, r, opts...)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// order
+	vals := make(map[string]interface{}, len(items))
+	if orderBy != "$key" {
+		for _, kv := range items {
+			vals[kv.Key] = orderValue(orderBy, kv.Value)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if orderBy != "$key" {
+			if c := compareValues(vals[items[i].Key], vals[items[j].Key]); c != 0 {
+				return c < 0
+			}
+		}
+		return keyLess(items[i].Key, items[j].Key)
+	})
+
+	// last page
+	if len(items) <= pageSize {
+		return items, "", nil
+	}
+
+	items = items[:pageSize]
+	last := items[pageSize-1]
+	if orderBy == "$key" {
+		return items, last.Key, nil
+	}
+
+	val, err := json.Marshal(vals[last.Key])
+	if err != nil {
+		return nil, "", &Error{
+			Err: fmt.Sprintf("could not marshal cursor: %v", err),
+		}
+	}
+	key, _ := json.Marshal(last.Key)
+
+	return items, string(val) + "," + string(key), nil
+}
+
+// GetPage retrieves a page of up to pageSize children stored at the Firebase
+// database ref, starting after cursor, returning the cursor for the next
+// page.
+func (r *DatabaseRef) GetPage(pageSize int, cursor string, opts ...QueryOption) ([]KeyedValue, string, error) {
+	return GetPage(r, pageSize, cursor, opts...)
+}
+
+// pageCursor returns a query option starting the page after cursor for the
+// order.
+func pageCursor(orderBy, cursor string) QueryOption {
+	if orderBy == "$key" {
+		return StartAfter(cursor)
+	}
+	return func(q *Query) error {
+		q.Values.Set("startAfter", cursor)
+		return nil
+	}
+}
+
+// orderValue returns the value of the JSON-encoded child buf used for
+// ordering by orderBy (ie, "$value", or a child path).
+func orderValue(orderBy string, buf []byte) interface{} {
+	var v interface{}
+	if err := (&Event{Data: buf}).Decode(&v); err != nil {
+		return nil
+	}
+	if orderBy == "$value" {
+		return v
+	}
+
+	for _, seg := range splitPath(orderBy) {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[seg]
+	}
+
+	return v
+}
+
+// compareValues compares the ordered values a and b as per Firebase's
+// ordering (ie, null, false, true, numbers, strings, and then objects),
+// returning -1, 0, or 1.
+func compareValues(a, b interface{}) int {
+	ra, rb := valueRank(a), valueRank(b)
+	switch {
+	case ra != rb:
+		return cmp(ra < rb, ra > rb)
+	case ra == 3:
+		x, _ := a.(json.Number).Float64()
+		y, _ := b.(json.Number).Float64()
+		return cmp(x < y, x > y)
+	case ra == 4:
+		x, y := a.(string), b.(string)
+		return cmp(x < y, x > y)
+	}
+	return 0
+}
+
+// valueRank returns the rank of the type of v in Firebase's ordering.
+func valueRank(v interface{}) int {
+	switch x := v.(type) {
+	case nil:
+		return 0
+	case bool:
+		if !x {
+			return 1
+		}
+		return 2
+	case json.Number:
+		return 3
+	case string:
+		return 4
+	}
+	return 5
+}
+
+// cmp returns -1 when less is true, 1 when greater is true, and 0 otherwise.
+func cmp(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
+
+// GetChunked retrieves the children stored at Firebase database ref r in key
+// order, chunkSize children per request, invoking fn for each child. The keys
+// of the children are first listed with a shallow request, and the children
+// are then retrieved in windows of chunkSize keys, so that very large nodes
+// can be retrieved without a single, large request.
+//
+// Children removed after the keys were listed are skipped. Retrieval stops
+// when fn returns an error, which is returned.
+func GetChunked(r *DatabaseRef, chunkSize int, fn func(key string, raw json.RawMessage) error) error {
+	return GetChunkedContext(context.Background(), r, "", chunkSize, fn)
+}
+
+// GetChunkedFrom retrieves the children stored at Firebase database ref r in
+// key order, starting at (and including) startKey, chunkSize children per
+// request, invoking fn for each child. GetChunkedFrom can be used to resume a
+// retrieval with the key of the last child passed to fn.
+func GetChunkedFrom(r *DatabaseRef, startKey string, chunkSize int, fn func(key string, raw json.RawMessage) error) error {
+	return GetChunkedContext(context.Background(), r, startKey, chunkSize, fn)
+}
+
+// GetChunkedContext retrieves the children stored at Firebase database ref r
+// in key order, starting at startKey (when not empty), chunkSize children per
+// request, invoking fn for each child, using the provided context.
+func GetChunkedContext(ctxt context.Context, r *DatabaseRef, startKey string, chunkSize int, fn func(key string, raw json.RawMessage) error) error {
+	if chunkSize < 1 {
+		return &Error{
+			Err: fmt.Sprintf("chunk size must be greater than 0, got: %d", chunkSize),
+		}
+	}
+
+	// list keys
+	list, err := GetOrderedContext(ctxt, r, Shallow)
+	if err != nil {
+		return err
+	}
+	var keys []string
+	for _, kv := range list {
+		if startKey == "" || !keyLess(kv.Key, startKey) {
+			keys = append(keys, kv.Key)
+		}
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		return keyLess(keys[i], keys[j])
+	})
+
+	return getChunks(ctxt, r, keys, chunkSize, fn)
+}
+
+// getChunks retrieves the children keys (sorted in key order) stored at
+// Firebase database ref r in windows of chunkSize keys, with query opts,
+// invoking fn for each child.
+func getChunks(ctxt context.Context, r *DatabaseRef, keys []string, chunkSize int, fn func(key string, raw json.RawMessage) error, opts ...QueryOption) error {
+	for i := 0; i < len(keys); i += chunkSize {
+		j := i + chunkSize
+		if j > len(keys) {
+			j = len(keys)
+		}
+
+		queryOpts := append([]QueryOption{OrderBy("$key"), StartAt(keys[i]), EndAt(keys[j-1])}, opts...)
+		chunk, err := GetOrderedContext(ctxt, r, queryOpts...)
+		if err != nil {
+			return err
+		}
+		sortKeyedValues(chunk)
+
+		for _, kv := range chunk {
+			if err := fn(kv.Key, kv.Value); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// GetChunked retrieves the children stored at the Firebase database ref in
+// key order, chunkSize children per request, invoking fn for each child.
+func (r *DatabaseRef) GetChunked(chunkSize int, fn func(key string, raw json.RawMessage) error) error {
+	return GetChunked(r, chunkSize, fn)
+}
@@ -0,0 +1,52 @@
+package firebase
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestChildrenError(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		if requests > 1 {
+			http.Error(w, `{"error":"permission denied"}`, http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"a":1,"b":2}`))
+	}))
+	defer srv.Close()
+
+	it := newTestRef(t, srv).Children(2)
+
+	var n int
+	for it.Next() {
+		n++
+	}
+	if n != 2 {
+		t.Errorf("expected 2 children, got: %d", n)
+	}
+	if err := it.Err(); err == nil {
+		t.Errorf("expected error")
+	}
+	if it.Next() {
+		t.Errorf("expected iteration to remain stopped")
+	}
+	if requests != 2 {
+		t.Errorf("expected 2 requests, got: %d", requests)
+	}
+}
+
+func TestKeyLess(t *testing.T) {
+	keys := []string{"b", "10", "a", "2", "-1", "01", "2147483648"}
+	sort.Slice(keys, func(i, j int) bool {
+		return keyLess(keys[i], keys[j])
+	})
+	exp := []string{"-1", "2", "10", "01", "2147483648", "a", "b"}
+	if !reflect.DeepEqual(keys, exp) {
+		t.Errorf("expected %v, got: %v", exp, keys)
+	}
+}
@@ -46,8 +46,16 @@ func main() {
 
 	// output events as received
 	for ev := range ch {
+		typ := strings.ToUpper(string(ev.Type))
+
+		// skip non-json data (ie, synthesized closed and error events)
+		if !json.Valid(ev.Data) {
+			log.Printf("%s", typ)
+			continue
+		}
+
 		// unmarshal data
-		var v map[string]interface{}
+		var v interface{}
 		err = json.Unmarshal(ev.Data, &v)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "error: %v\n", err)
@@ -61,6 +69,10 @@ func main() {
 			os.Exit(1)
 		}
 
-		log.Printf("%s: %s", strings.ToUpper(string(ev.Type)), string(buf))
+		if ev.Path != "" {
+			log.Printf("%s %s: %s", typ, ev.Path, string(buf))
+		} else {
+			log.Printf("%s: %s", typ, string(buf))
+		}
 	}
 }
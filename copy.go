@@ -0,0 +1,146 @@
+package firebase
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Copy copies the values stored at Firebase database ref src to Firebase
+// database ref dst, replacing the values stored at dst. The values are copied
+// verbatim (see GetRaw).
+//
+// ErrNodeNotExists is returned when no value is stored at src. Copy refuses to
+// copy between refs where one is contained in the other (ie, src is a child
+// of dst, or dst is a child of src).
+func Copy(src, dst *DatabaseRef) error {
+	return CopyContext(context.Background(), src, dst)
+}
+
+// CopyContext copies the values stored at Firebase database ref src to
+// Firebase database ref dst, using the provided context.
+func CopyContext(ctxt context.Context, src, dst *DatabaseRef) error {
+	if err := checkOverlap(src, dst); err != nil {
+		return err
+	}
+
+	buf, err := GetRawContext(ctxt, src)
+	if err != nil {
+		return err
+	}
+	if isNull(buf) {
+		return ErrNodeNotExists
+	}
+
+	return SetContext(ctxt, dst, []byte(buf), PrintSilent)
+}
+
+// Move moves the values stored at Firebase database ref src to Firebase
+// database ref dst, by copying the values (see Copy) and removing the values
+// stored at src only after the values were written to dst.
+//
+// Values written to src after being copied are lost. Use MoveIfUnchanged to
+// abort the move instead.
+func Move(src, dst *DatabaseRef) error {
+	return MoveContext(context.Background(), src, dst)
+}
+
+// MoveContext moves the values stored at Firebase database ref src to
+// Firebase database ref dst, using the provided context.
+func MoveContext(ctxt context.Context, src, dst *DatabaseRef) error {
+	if err := CopyContext(ctxt, src, dst); err != nil {
+		return err
+	}
+
+	return RemoveContext(ctxt, src)
+}
+
+// MoveIfUnchanged moves the values stored at Firebase database ref src to
+// Firebase database ref dst, removing the values stored at src only when they
+// were not modified after being copied (see RemoveIfUnchanged).
+//
+// When src was modified, an *ETagMismatchError is returned, and the values of
+// both src and dst are retained (ie, dst holds the values as copied).
+func MoveIfUnchanged(src, dst *DatabaseRef) error {
+	return MoveIfUnchangedContext(context.Background(), src, dst)
+}
+
+// MoveIfUnchangedContext moves the values stored at Firebase database ref src
+// to Firebase database ref dst, removing the values stored at src only when
+// they were not modified after being copied, using the provided context.
+func MoveIfUnchangedContext(ctxt context.Context, src, dst *DatabaseRef) error {
+	if err := checkOverlap(src, dst); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	etag, err := GetWithETagContext(ctxt, src, &buf)
+	if err != nil {
+		return err
+	}
+	if buf.Len() == 0 || isNull(buf.Bytes()) {
+		return ErrNodeNotExists
+	}
+
+	if err = SetContext(ctxt, dst, buf.Bytes(), PrintSilent); err != nil {
+		return err
+	}
+
+	return RemoveIfUnchangedContext(ctxt, src, etag)
+}
+
+// checkOverlap returns an error when refs a and b are the same ref, or one is
+// contained in the other.
+func checkOverlap(a, b *DatabaseRef) error {
+	ua, ub := a.URL(), b.URL()
+	if ua.Scheme != ub.Scheme || ua.Host != ub.Host || ua.Query().Get("ns") != ub.Query().Get("ns") {
+		return nil
+	}
+
+	pa := "/" + strings.Join(splitPath(ua.Path), "/")
+	pb := "/" + strings.Join(splitPath(ub.Path), "/")
+	if pa == pb || strings.HasPrefix(pa, strings.TrimSuffix(pb, "/")+"/") || strings.HasPrefix(pb, strings.TrimSuffix(pa, "/")+"/") {
+		return &Error{
+			Err: fmt.Sprintf("cannot copy between overlapping refs %s and %s", pa, pb),
+		}
+	}
+
+	return nil
+}
+
+// Copy copies the values stored at the Firebase database ref to dst.
+func (r *DatabaseRef) Copy(dst *DatabaseRef) error {
+	return Copy(r, dst)
+}
+
+// CopyContext copies the values stored at the Firebase database ref to dst,
+// using the provided context.
+func (r *DatabaseRef) CopyContext(ctxt context.Context, dst *DatabaseRef) error {
+	return CopyContext(ctxt, r, dst)
+}
+
+// Move moves the values stored at the Firebase database ref to dst.
+func (r *DatabaseRef) Move(dst *DatabaseRef) error {
+	return Move(r, dst)
+}
+
+// MoveContext moves the values stored at the Firebase database ref to dst,
+// using the provided context.
+func (r *DatabaseRef) MoveContext(ctxt context.Context, dst *DatabaseRef) error {
+	return MoveContext(ctxt, r, dst)
+}
+
+// MoveIfUnchanged moves the values stored at the Firebase database ref to
+// dst, removing the values only when they were not modified after being
+// copied.
+func (r *DatabaseRef) MoveIfUnchanged(dst *DatabaseRef) error {
+	return MoveIfUnchanged(r, dst)
+}
+
+// MoveIfUnchangedContext moves the values stored at the Firebase database ref
+// to dst, removing the values only when they were not modified after being
+// copied, using the provided context.
+func (r *DatabaseRef) MoveIfUnchangedContext(ctxt context.Context, dst *DatabaseRef) error {
+	return MoveIfUnchangedContext(ctxt, r, dst)
+}
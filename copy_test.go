@@ -0,0 +1,86 @@
+package firebase_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/knq/firebase"
+	"github.com/knq/firebase/firebasetest"
+)
+
+// roundTripFunc is a http.RoundTripper func.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip satisfies the http.RoundTripper interface.
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestCopyMove(t *testing.T) {
+	srv := firebasetest.NewServer()
+	defer srv.Close()
+
+	if err := srv.SetData("/pending/x", map[string]interface{}{"b": 1.50, "a": 2}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	r := srv.Ref()
+
+	// copy
+	if err := r.Ref("/pending/x").Copy(r.Ref("/copy/x")); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if a, b := string(srv.Data("/pending/x")), string(srv.Data("/copy/x")); a != b || a == "null" {
+		t.Errorf("expected copied values, got: %s and %s", a, b)
+	}
+
+	// move
+	if err := r.Ref("/copy/x").Move(r.Ref("/approved/x")); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s := string(srv.Data("/copy")); s != "null" {
+		t.Errorf("expected source to be removed, got: %s", s)
+	}
+	if a, b := string(srv.Data("/pending/x")), string(srv.Data("/approved/x")); a != b {
+		t.Errorf("expected moved values, got: %s and %s", a, b)
+	}
+
+	// missing source
+	if err := r.Ref("/missing").Move(r.Ref("/approved/y")); !errors.Is(err, firebase.ErrNodeNotExists) {
+		t.Errorf("expected ErrNodeNotExists, got: %v", err)
+	}
+
+	// overlapping refs
+	for _, p := range [][2]string{{"/pending", "/pending/x/y"}, {"/pending/x/y", "/pending"}, {"/pending", "/pending"}, {"/", "/a"}} {
+		if err := r.Ref(p[0]).Copy(r.Ref(p[1])); err == nil {
+			t.Errorf("expected error copying %s to %s", p[0], p[1])
+		}
+	}
+
+	// concurrent modification aborts the move
+	r = srv.Ref(firebase.Transport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		res, err := http.DefaultTransport.RoundTrip(req)
+		if err == nil && req.Method == "PUT" {
+			srv.SetData("/pending/x/c", true)
+		}
+		return res, err
+	})))
+	err := r.Ref("/pending/x").MoveIfUnchanged(r.Ref("/moved/x"))
+	if !errors.Is(err, firebase.ErrETagMismatch) {
+		t.Fatalf("expected ErrETagMismatch, got: %v", err)
+	}
+	if s := string(srv.Data("/pending/x/c")); s != "true" {
+		t.Errorf("expected source to be retained, got: %s", s)
+	}
+	if s := string(srv.Data("/moved/x/a")); s != "2" {
+		t.Errorf("expected destination to hold the copied values, got: %s", s)
+	}
+
+	// unmodified
+	if err := srv.Ref().Ref("/pending/x").MoveIfUnchanged(srv.Ref().Ref("/moved/x")); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s := string(srv.Data("/pending")); s != "null" {
+		t.Errorf("expected source to be removed, got: %s", s)
+	}
+}
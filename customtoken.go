@@ -0,0 +1,146 @@
+package firebase
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/knq/jwt/gserviceaccount"
+)
+
+const (
+	// CustomTokenAudience is the audience of Firebase custom tokens.
+	CustomTokenAudience = "https://identitytoolkit.googleapis.com/google.identity.identitytoolkit.v1.IdentityToolkit"
+
+	// CustomTokenMaxExpiry is the maximum expiry of a Firebase custom token.
+	CustomTokenMaxExpiry = 1 * time.Hour
+
+	// CustomTokenMaxUIDLen is the maximum length of the uid for a Firebase
+	// custom token.
+	CustomTokenMaxUIDLen = 128
+)
+
+// reservedClaims are the claim names that cannot be used as custom claims in
+// a Firebase custom token.
+var reservedClaims = []string{
+	"acr", "amr", "at_hash", "aud", "auth_time", "azp", "cnf", "c_hash",
+	"exp", "firebase", "iat", "iss", "jti", "nbf", "nonce", "sub",
+}
+
+// CustomToken creates a Firebase custom token for uid with the provided
+// custom claims, signed (RS256) with the JSON encoded Google Service Account
+// credentials in creds.
+//
+// The expiry of the token is capped at CustomTokenMaxExpiry (one hour). If
+// expires is 0, then the token will expire after CustomTokenMaxExpiry.
+//
+// See: https://firebase.google.com/docs/auth/admin/create-custom-tokens
+func CustomToken(creds []byte, uid string, claims map[string]interface{}, expires time.Duration) (string, error) {
+	var err error
+
+	// check uid
+	if uid == "" {
+		return "", errors.New("custom token uid cannot be empty")
+	}
+	if len(uid) > CustomTokenMaxUIDLen {
+		return "", fmt.Errorf("custom token uid cannot be longer than %d characters", CustomTokenMaxUIDLen)
+	}
+
+	// check claims
+	for _, k := range reservedClaims {
+		if _, ok := claims[k]; ok {
+			return "", fmt.Errorf("custom token claim %q is reserved", k)
+		}
+	}
+
+	// check expiry
+	if expires < 0 {
+		return "", errors.New("custom token expiry cannot be negative")
+	}
+	if expires == 0 || expires > CustomTokenMaxExpiry {
+		expires = CustomTokenMaxExpiry
+	}
+
+	// load service account credentials
+	gsa, err := gserviceaccount.FromJSON(creds)
+	if err != nil {
+		return "", err
+	}
+	if gsa.ClientEmail == "" || gsa.PrivateKey == "" {
+		return "", errors.New("google service account credentials missing client_email or private_key")
+	}
+
+	key, err := parseRSAPrivateKey([]byte(gsa.PrivateKey))
+	if err != nil {
+		return "", err
+	}
+
+	// build claims
+	now := time.Now()
+	c := map[string]interface{}{
+		"iss": gsa.ClientEmail,
+		"sub": gsa.ClientEmail,
+		"aud": CustomTokenAudience,
+		"iat": now.Unix(),
+		"exp": now.Add(expires).Unix(),
+		"uid": uid,
+	}
+	if len(claims) != 0 {
+		c["claims"] = claims
+	}
+
+	// encode header and claims
+	header := map[string]interface{}{"alg": "RS256", "typ": "JWT"}
+	if gsa.PrivateKeyID != "" {
+		header["kid"] = gsa.PrivateKeyID
+	}
+	h, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	p, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	tok := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(p)
+
+	// sign
+	sum := sha256.Sum256([]byte(tok))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+
+	return tok + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// parseRSAPrivateKey parses a PEM encoded PKCS#8 or PKCS#1 RSA private key.
+func parseRSAPrivateKey(buf []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(buf)
+	if block == nil {
+		return nil, errors.New("invalid private key")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.New("invalid private key")
+	}
+	key, ok := k.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not a RSA private key")
+	}
+
+	return key, nil
+}
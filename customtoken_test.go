@@ -0,0 +1,114 @@
+package firebase
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestCreds generates a RSA key and returns it with JSON encoded Google
+// Service Account credentials using the key.
+func newTestCreds(t *testing.T) (*rsa.PrivateKey, []byte) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("could not marshal key: %v", err)
+	}
+
+	creds, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "test",
+		"private_key_id": "kid",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"client_email":   "test@test.iam.gserviceaccount.com",
+	})
+	if err != nil {
+		t.Fatalf("could not marshal creds: %v", err)
+	}
+
+	return key, creds
+}
+
+func TestCustomToken(t *testing.T) {
+	key, creds := newTestCreds(t)
+
+	tok, err := CustomToken(creds, "user1", map[string]interface{}{"admin": true}, 2*time.Hour)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	parts := strings.Split(tok, ".")
+	if len(parts) != 3 {
+		t.Fatalf("expected 3 parts, got: %d", len(parts))
+	}
+
+	// verify signature
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatalf("could not decode signature: %v", err)
+	}
+	sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err = rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, sum[:], sig); err != nil {
+		t.Fatalf("expected valid signature, got: %v", err)
+	}
+
+	// check claims
+	buf, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatalf("could not decode claims: %v", err)
+	}
+	var c struct {
+		Iss, Sub, Aud, UID string
+		Iat, Exp           int64
+		Claims             map[string]interface{}
+	}
+	if err = json.Unmarshal(buf, &c); err != nil {
+		t.Fatalf("could not unmarshal claims: %v", err)
+	}
+	if c.Iss != "test@test.iam.gserviceaccount.com" || c.Sub != c.Iss {
+		t.Errorf("expected iss and sub to be client email, got: %q, %q", c.Iss, c.Sub)
+	}
+	if c.Aud != CustomTokenAudience {
+		t.Errorf("expected aud %s, got: %s", CustomTokenAudience, c.Aud)
+	}
+	if c.UID != "user1" {
+		t.Errorf("expected uid user1, got: %s", c.UID)
+	}
+	if d := c.Exp - c.Iat; d != int64(CustomTokenMaxExpiry/time.Second) {
+		t.Errorf("expected expiry to be capped at one hour, got: %ds", d)
+	}
+	if c.Claims["admin"] != true {
+		t.Errorf("expected admin claim, got: %v", c.Claims)
+	}
+}
+
+func TestCustomTokenInvalid(t *testing.T) {
+	_, creds := newTestCreds(t)
+
+	tests := []struct {
+		uid    string
+		claims map[string]interface{}
+	}{
+		{"", nil},
+		{strings.Repeat("a", CustomTokenMaxUIDLen+1), nil},
+		{"user1", map[string]interface{}{"sub": "other"}},
+		{"user1", map[string]interface{}{"firebase": true}},
+	}
+
+	for i, test := range tests {
+		if _, err := CustomToken(creds, test.uid, test.claims, 0); err == nil {
+			t.Errorf("test %d expected error", i)
+		}
+	}
+}
@@ -8,10 +8,15 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/oauth2"
 )
@@ -20,6 +25,12 @@ const (
 	// DefaultWatchBuffer is the default length of an event channel created on
 	// a call to Watch.
 	DefaultWatchBuffer = 64
+
+	// Version is the version of the package.
+	Version = "0.1.0"
+
+	// DefaultUserAgent is the default User-Agent sent with requests.
+	DefaultUserAgent = "firebase-go/" + Version
 )
 
 // OpType is the Firebase operation type.
@@ -45,239 +56,1624 @@ const (
 // Do executes an HTTP operation on Firebase database ref r passing the
 // supplied value v as JSON marshaled data and decoding the response to d.
 func Do(op OpType, r *DatabaseRef, v, d interface{}, opts ...QueryOption) error {
-	var err error
+	return DoContext(context.Background(), op, r, v, d, opts...)
+}
+
+// DoContext executes an HTTP operation on Firebase database ref r passing the
+// supplied value v as JSON marshaled data and decoding the response to d.
+//
+// When v is an io.Reader or []byte, it is sent as-is. When d is an io.Writer,
+// the raw response body is copied to d.
+//
+// The request is bound to ctxt, and is aborted if ctxt is done before the
+// request completes. In that case, the returned *Error wraps the context's
+// error, such that errors.Is(err, context.Canceled) (or
+// context.DeadlineExceeded) reports true.
+func DoContext(ctxt context.Context, op OpType, r *DatabaseRef, v, d interface{}, opts ...QueryOption) error {
+	_, err := doRequest(ctxt, op, r, v, d, opts...)
+	return err
+}
+
+// doRequest executes an HTTP operation on Firebase database ref r, in the same
+// manner as DoContext, returning the server's response so that callers can
+// inspect the response's status code and headers.
+//
+// The returned response's body will have already been consumed and closed.
+func doRequest(ctxt context.Context, op OpType, r *DatabaseRef, v, d interface{}, opts ...QueryOption) (*http.Response, error) {
+	r.rw.RLock()
+	cache := r.cache
+	r.rw.RUnlock()
+	if cache == nil {
+		return execRequest(ctxt, op, r, v, d, opts...)
+	}
+
+	// serve reads from the cache, and invalidate the cache on writes
+	if op == OpTypeGet {
+		if res, ok, err := cache.get(ctxt, r, d, opts); ok {
+			return res, err
+		}
+		return execRequest(ctxt, op, r, v, d, opts...)
+	}
+	defer cache.invalidate(r.URL().Path)
+
+	return execRequest(ctxt, op, r, v, d, opts...)
+}
+
+// execRequest executes an HTTP operation on Firebase database ref r for
+// doRequest, bypassing the read cache.
+func execRequest(ctxt context.Context, op OpType, r *DatabaseRef, v, d interface{}, opts ...QueryOption) (_ *http.Response, err error) {
+	r.rw.RLock()
+	ro, compressMin := r.retryOpts, r.compressMin
+	idHeader, idGen := r.requestIDHeader, r.requestIDGen
+	dryRun, hasQueryOpts, codec := r.dryRun, len(r.queryOpts) != 0, r.codec
+	r.rw.RUnlock()
+
+	// generate request id
+	var id string
+	if idHeader != "" {
+		id = idGen()
+	}
+
+	// identify the request in errors
+	defer func() {
+		if e, ok := err.(*Error); ok {
+			if e.Method == "" {
+				e.Method, e.Path = string(op), r.URL().Path
+			}
+			if e.RequestID == "" {
+				e.RequestID = id
+			}
+		}
+	}()
+	retry := ro.enabled(string(op))
+	record := dryRun != nil && op != OpTypeGet
+
+	// determine if the encoded value is streamed (see StreamEncode)
+	var stream bool
+	switch v.(type) {
+	case nil, []byte, io.Reader:
+	default:
+		if !record && (len(opts) != 0 || hasQueryOpts) {
+			q, err := r.buildQuery(opts...)
+			if err != nil {
+				return nil, &Error{
+					Err: fmt.Sprintf("could not create request: %v", err),
+				}
+			}
+			stream = q.Stream
+		}
+	}
 
 	// encode v
+	var buf []byte
 	var body io.Reader
+	var sb *streamBody
 	switch x := v.(type) {
+	case *streamBody:
+		if record {
+			if buf, err = ioutil.ReadAll(x.Reader); err != nil {
+				return nil, &Error{
+					Err: fmt.Sprintf("could not read body: %v", err),
+					err: err,
+				}
+			}
+			break
+		}
+		body, sb = x, x
+
 	case io.Reader:
 		body = x
 
+		// read body, so that it can be resent on retry (or recorded)
+		if retry || record {
+			buf, err = ioutil.ReadAll(x)
+			if err != nil {
+				return nil, &Error{
+					Err: fmt.Sprintf("could not read body: %v", err),
+					err: err,
+				}
+			}
+			body = nil
+		}
+
 	case []byte:
-		body = bytes.NewReader(x)
+		buf = x
 
 	default:
-		if v != nil {
-			buf, err := json.Marshal(v)
-			if err != nil {
-				return &Error{
+		if v != nil && stream {
+			sb = encodeStream(v, codec)
+			body = sb
+			defer sb.close()
+		} else if v != nil && codec != nil {
+			if buf, err = codec.Marshal(v); err != nil {
+				return nil, &Error{
+					Err: fmt.Sprintf("could not marshal json: %v", err),
+				}
+			}
+		} else if v != nil {
+			b := getBuffer()
+			if err = json.NewEncoder(b).Encode(v); err != nil {
+				putBuffer(b)
+				return nil, &Error{
 					Err: fmt.Sprintf("could not marshal json: %v", err),
 				}
 			}
+			buf = bytes.TrimSuffix(b.Bytes(), []byte("\n"))
+
+			// return the buffer to the pool only once the request has
+			// succeeded, as the transport may still be reading the body of
+			// a failed request
+			defer func() {
+				if err == nil {
+					putBuffer(b)
+				}
+			}()
+		}
+	}
+
+	// record writes instead of executing them
+	if record {
+		return recordDryRun(dryRun, op, r, buf, d, r.decoder(opts...))
+	}
+
+	// compress large bodies (once, so that the compressed body can be resent
+	// on retry)
+	if compressMin > 0 && len(buf) > compressMin {
+		buf, err = gzipBytes(buf)
+		if err != nil {
+			return nil, &Error{
+				Err: fmt.Sprintf("could not compress body: %v", err),
+				err: err,
+			}
+		}
+		opts = append(opts[:len(opts):len(opts)], gzipContentEncoding)
+	}
+
+	for attempt := 1; ; attempt++ {
+		// a new reader is used for each attempt (a *bytes.Reader body also
+		// allows the http.Request's body to be re-read via GetBody)
+		if buf != nil {
 			body = bytes.NewReader(buf)
 		}
+
+		attemptOpts := opts
+		if id != "" {
+			attemptOpts = append(opts[:len(opts):len(opts)], requestID(idHeader, id, attempt))
+		}
+
+		res, err := doAttempt(context.WithValue(ctxt, attemptKey{}, attempt), op, r, body, d, attemptOpts...)
+
+		// streamed bodies cannot be resent, and errors reading (or
+		// encoding) the body take precedence over the request's error
+		if sb != nil {
+			if e := sb.Err(); e != nil {
+				return nil, e
+			}
+			return res, err
+		}
+
+		if err == nil {
+			return res, nil
+		}
+
+		// retry throttled requests and transient failures
+		if ctxt.Err() != nil {
+			return nil, err
+		}
+		wait, ok := ro.retry(attempt, string(op), res, err)
+		if !ok {
+			return nil, retryError(attempt, err)
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+		case <-ctxt.Done():
+			t.Stop()
+			return nil, &Error{
+				Err: fmt.Sprintf("could not execute request: %v", ctxt.Err()),
+				err: ctxt.Err(),
+			}
+		}
+	}
+}
+
+// Operation is a write operation recorded in dry-run mode (see DryRun).
+type Operation struct {
+	// Method is the operation's type (ie, OpTypeSet).
+	Method OpType
+
+	// Path is the database path of the operation.
+	Path string
+
+	// Payload is the JSON-encoded value of the operation, or nil for removes.
+	Payload json.RawMessage
+}
+
+// recordDryRun records the write op with the encoded body buf on Firebase
+// database ref r, decoding the response Firebase would have returned into d
+// (ie, a generated name for pushes).
+func recordDryRun(dryRun func(Operation), op OpType, r *DatabaseRef, buf []byte, d interface{}, dc decoder) (*http.Response, error) {
+	dryRun(Operation{
+		Method:  op,
+		Path:    r.URL().Path,
+		Payload: append(json.RawMessage(nil), buf...),
+	})
+
+	res := &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       ioutil.NopCloser(bytes.NewReader(nil)),
+	}
+
+	// firebase returns the pushed name, and the written value for sets and
+	// updates
+	switch {
+	case d == nil:
+		return res, nil
+	case op == OpTypePush:
+		buf, _ = json.Marshal(map[string]string{"name": GeneratePushID()})
+	case op == OpTypeRemove || buf == nil:
+		buf = []byte("null")
+	}
+	if w, ok := d.(io.Writer); ok {
+		_, err := w.Write(buf)
+		return res, err
 	}
+	if err := dc.decode(buf, d); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
 
+// doAttempt executes a single HTTP request for doRequest, with the provided
+// body.
+//
+// The server's response is returned along with any server error, so that the
+// caller can determine if the request should be retried.
+func doAttempt(ctxt context.Context, op OpType, r *DatabaseRef, body io.Reader, d interface{}, opts ...QueryOption) (_ *http.Response, err error) {
 	// create client and request
-	client, req, err := r.clientAndRequest(string(op), body, opts...)
+	client, req, cancel, err := r.clientAndRequest(ctxt, string(op), body, opts...)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer cancel()
+
+	// log request and record metrics
+	r.rw.RLock()
+	rl, mh, path, maxRes := r.reqLog, r.metrics, r.url.Path, r.maxResponseBytes
+	r.rw.RUnlock()
+	var res *http.Response
+	var cb *countingBody
+	start := time.Now()
+	defer func() {
+		rl.request(req, res, cb, start, err)
+		if mh != nil {
+			status, respBytes := 0, int64(-1)
+			if res != nil {
+				status = res.StatusCode
+			}
+			if cb != nil {
+				respBytes = cb.n
+			}
+			mh.RequestDone(string(op), path, status, time.Since(start), req.ContentLength, respBytes, err)
+		}
+	}()
 
 	// execute
-	res, err := client.Do(req)
+	res, err = client.Do(req)
 	if err != nil {
-		return &Error{
+		// pass through errors from the token source
+		var e *Error
+		if errors.As(err, &e) {
+			return nil, e
+		}
+
+		err = redactError(err)
+		return nil, &Error{
 			Err: fmt.Sprintf("could not execute request: %v", err),
+			err: err,
 		}
 	}
-	defer res.Body.Close()
+	// close the (possibly wrapped) body
+	defer func() {
+		res.Body.Close()
+	}()
+
+	// decompress body, prior to counting the body for the request log
+	err = gunzipBody(res)
+	if err != nil {
+		return res, err
+	}
+	lb := limitBody(res, maxRes)
+	if rl.logf != nil || mh != nil {
+		cb = countBody(res, rl.bodyMax)
+	}
 
 	// check for server error
 	err = checkServerError(res)
 	if err != nil {
-		return err
+		return res, lb.tooLarge(err)
 	}
 
-	// decode body to d
-	if d != nil {
-		dec := json.NewDecoder(res.Body)
-		dec.UseNumber()
-		err = dec.Decode(d)
-		if err != nil {
-			return &Error{
+	// decode body to d (no content is returned with print=silent, or when
+	// not modified)
+	if d != nil && res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusNotModified {
+		// copy raw body
+		if w, ok := d.(io.Writer); ok {
+			_, err = io.Copy(w, res.Body)
+			if err != nil {
+				if err := lb.tooLarge(nil); err != nil {
+					return nil, err
+				}
+				return nil, &Error{
+					Err: fmt.Sprintf("could not read body: %v", err),
+					err: err,
+				}
+			}
+			return res, nil
+		}
+
+		err = r.decoder(opts...).decodeFrom(res.Body, d)
+		if err != nil && err != io.EOF {
+			if err := lb.tooLarge(nil); err != nil {
+				return nil, err
+			}
+
+			// wrap the context's error when the read was interrupted
+			cause := err
+			if ce := req.Context().Err(); ce != nil {
+				cause = ce
+			}
+			return nil, &Error{
 				Err: fmt.Sprintf("could not unmarshal json: %v", err),
+				err: cause,
 			}
 		}
 	}
 
-	return nil
+	return res, nil
 }
 
 // Get retrieves the values stored at Firebase database ref r and decodes them
 // into d.
 func Get(r *DatabaseRef, d interface{}, opts ...QueryOption) error {
-	return Do(OpTypeGet, r, nil, d, opts...)
+	return GetContext(context.Background(), r, d, opts...)
+}
+
+// GetContext retrieves the values stored at Firebase database ref r and
+// decodes them into d, using the provided context.
+func GetContext(ctxt context.Context, r *DatabaseRef, d interface{}, opts ...QueryOption) error {
+	return DoContext(ctxt, OpTypeGet, r, nil, d, priorityOpts(d, opts)...)
+}
+
+// GetStrict retrieves the values stored at Firebase database ref r and decodes
+// them into d, returning ErrNodeNotExists when no value is stored at r (ie,
+// the server returns null).
+func GetStrict(r *DatabaseRef, d interface{}, opts ...QueryOption) error {
+	return GetStrictContext(context.Background(), r, d, opts...)
+}
+
+// GetStrictContext retrieves the values stored at Firebase database ref r and
+// decodes them into d, using the provided context, returning ErrNodeNotExists
+// when no value is stored at r.
+func GetStrictContext(ctxt context.Context, r *DatabaseRef, d interface{}, opts ...QueryOption) error {
+	buf, err := GetRawContext(ctxt, r, priorityOpts(d, opts)...)
+	if err != nil {
+		return err
+	}
+
+	// non-existent node
+	if isNull(buf) {
+		return ErrNodeNotExists
+	}
+
+	err = r.decoder(opts...).decodeFrom(bytes.NewReader(buf), d)
+	if err != nil {
+		return &Error{
+			Err:    fmt.Sprintf("could not unmarshal json: %v", err),
+			Method: string(OpTypeGet),
+			Path:   r.URL().Path,
+			err:    err,
+		}
+	}
+
+	return nil
+}
+
+// GetRaw retrieves the values stored at Firebase database ref r, returning the
+// JSON response body verbatim (ie, without reordering keys or changing the
+// formatting of numbers), for storing or decoding later. The literal null is
+// returned when no value is stored at r.
+//
+// As with Get, compressed responses are transparently decompressed, and the
+// response is subject to the MaxResponseBytes limit.
+func GetRaw(r *DatabaseRef, opts ...QueryOption) (json.RawMessage, error) {
+	return GetRawContext(context.Background(), r, opts...)
+}
+
+// GetRawContext retrieves the values stored at Firebase database ref r,
+// returning the JSON response body verbatim, using the provided context.
+func GetRawContext(ctxt context.Context, r *DatabaseRef, opts ...QueryOption) (json.RawMessage, error) {
+	var buf bytes.Buffer
+	err := GetContext(ctxt, r, &buf, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// no content
+	if buf.Len() == 0 {
+		return json.RawMessage("null"), nil
+	}
+
+	return buf.Bytes(), nil
+}
+
+// GetReader retrieves the values stored at Firebase database ref r, returning
+// the undecoded JSON response body for the caller to consume (ie, to copy to
+// a file). Compressed responses are transparently decompressed, and server
+// errors are returned as the error.
+//
+// The caller owns the returned body and must close it, as the request's
+// connection (and context) is not released until the body is closed.
+func GetReader(r *DatabaseRef, opts ...QueryOption) (io.ReadCloser, error) {
+	return GetReaderContext(context.Background(), r, opts...)
+}
+
+// GetReaderContext retrieves the values stored at Firebase database ref r,
+// returning the undecoded JSON response body, using the provided context. The
+// caller must close the returned body.
+func GetReaderContext(ctxt context.Context, r *DatabaseRef, opts ...QueryOption) (_ io.ReadCloser, err error) {
+	// identify the request in errors
+	defer func() {
+		if e, ok := err.(*Error); ok && e.Method == "" {
+			e.Method, e.Path = string(OpTypeGet), r.URL().Path
+		}
+	}()
+
+	client, req, cancel, err := r.clientAndRequest(ctxt, string(OpTypeGet), nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	r.rw.RLock()
+	rl, maxRes := r.reqLog, r.maxResponseBytes
+	r.rw.RUnlock()
+
+	// execute
+	start := time.Now()
+	res, err := client.Do(req)
+	if err != nil {
+		rl.request(req, nil, nil, start, err)
+		cancel()
+
+		// pass through errors from the token source
+		var e *Error
+		if errors.As(err, &e) {
+			return nil, e
+		}
+
+		err = redactError(err)
+		return nil, &Error{
+			Err: fmt.Sprintf("could not execute request: %v", err),
+			err: err,
+		}
+	}
+
+	// check server error (decompressing the body)
+	err = checkServerError(res)
+	rl.request(req, res, nil, start, err)
+	if err != nil {
+		res.Body.Close()
+		cancel()
+		return nil, err
+	}
+	limitBody(res, maxRes)
+
+	return &cancelBody{ReadCloser: res.Body, cancel: cancel}, nil
+}
+
+// cancelBody is a response body that cancels the request's context when
+// closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close satisfies the io.Closer interface.
+func (cb *cancelBody) Close() error {
+	err := cb.ReadCloser.Close()
+	cb.cancel()
+	return err
+}
+
+// Exists determines if a value is stored at Firebase database ref r, without
+// retrieving the children's values (ie, with a shallow request, as Firebase
+// does not allow shallow to be combined with other query parameters, such as
+// limitToFirst). Errors are returned as with Get.
+func Exists(r *DatabaseRef, opts ...QueryOption) (bool, error) {
+	return ExistsContext(context.Background(), r, opts...)
+}
+
+// ExistsContext determines if a value is stored at Firebase database ref r,
+// using the provided context.
+func ExistsContext(ctxt context.Context, r *DatabaseRef, opts ...QueryOption) (bool, error) {
+	buf, err := GetRawContext(ctxt, r, append([]QueryOption{Shallow}, opts...)...)
+	if err != nil {
+		return false, err
+	}
+	return !isNull(buf), nil
+}
+
+// isNull returns true when buf is empty or the JSON literal null.
+func isNull(buf []byte) bool {
+	buf = bytes.TrimSpace(buf)
+	return len(buf) == 0 || string(buf) == "null"
 }
 
 // Set stores values v at Firebase database ref r.
 func Set(r *DatabaseRef, v interface{}, opts ...QueryOption) error {
-	return Do(OpTypeSet, r, v, nil, opts...)
+	return SetContext(context.Background(), r, v, opts...)
+}
+
+// SetContext stores values v at Firebase database ref r, using the provided
+// context.
+func SetContext(ctxt context.Context, r *DatabaseRef, v interface{}, opts ...QueryOption) error {
+	// firebase rejects increments as the root value of a write
+	if isServerIncrement(v) {
+		return &Error{
+			Err: "cannot set a server increment as the root value (use Update on the parent ref)",
+		}
+	}
+
+	return DoContext(ctxt, OpTypeSet, r, v, nil, opts...)
+}
+
+// SetReader stores the JSON-encoded value read from src at Firebase database
+// ref r, streaming src as the request body (with chunked encoding) instead of
+// reading it into memory.
+//
+// As src is streamed, the request is not retried. An error reading from src
+// is returned as the error.
+func SetReader(r *DatabaseRef, src io.Reader, opts ...QueryOption) error {
+	return SetReaderContext(context.Background(), r, src, opts...)
+}
+
+// SetReaderContext stores the JSON-encoded value read from src at Firebase
+// database ref r, streaming src as the request body, using the provided
+// context.
+func SetReaderContext(ctxt context.Context, r *DatabaseRef, src io.Reader, opts ...QueryOption) error {
+	return DoContext(ctxt, OpTypeSet, r, &streamBody{Reader: src, op: "read"}, nil, append(opts[:len(opts):len(opts)], PrintSilent)...)
 }
 
 // Push pushes values v to Firebase database ref r, returning the name (ID) of
 // the pushed node.
+//
+// Push returns an error when used with the PrintSilent query option, as the
+// name of the pushed node is not returned by Firebase in silent mode.
 func Push(r *DatabaseRef, v interface{}, opts ...QueryOption) (string, error) {
+	return PushContext(context.Background(), r, v, opts...)
+}
+
+// PushContext pushes values v to Firebase database ref r, returning the name
+// (ID) of the pushed node, using the provided context.
+func PushContext(ctxt context.Context, r *DatabaseRef, v interface{}, opts ...QueryOption) (string, error) {
+	// the pushed name is not returned by firebase with print=silent
+	q, err := r.buildQuery(opts...)
+	if err != nil {
+		return "", &Error{
+			Err: fmt.Sprintf("could not create request: %v", err),
+		}
+	}
+	if q.Values.Get("print") == "silent" {
+		return "", &Error{
+			Err: "push cannot return the pushed name with PrintSilent",
+		}
+	}
+
+	// decode the name with encoding/json, regardless of the codec
+	var buf bytes.Buffer
+	err = DoContext(ctxt, OpTypePush, r, v, &buf, opts...)
+	if err != nil {
+		return "", err
+	}
+
 	var res struct {
 		Name string `json:"name"`
 	}
+	if err = json.Unmarshal(buf.Bytes(), &res); err != nil {
+		return "", &Error{
+			Err:    fmt.Sprintf("could not unmarshal json: %v", err),
+			Method: string(OpTypePush),
+			Path:   r.URL().Path,
+		}
+	}
+
+	return res.Name, nil
+}
+
+// PushRef pushes values v to Firebase database ref r, returning a child
+// database ref for the pushed node.
+//
+// The returned database ref inherits the configuration (ie, credentials,
+// transport, and default options) of r.
+func PushRef(r *DatabaseRef, v interface{}, opts ...QueryOption) (*DatabaseRef, error) {
+	return PushRefContext(context.Background(), r, v, opts...)
+}
+
+// PushRefContext pushes values v to Firebase database ref r, returning a child
+// database ref for the pushed node, using the provided context.
+func PushRefContext(ctxt context.Context, r *DatabaseRef, v interface{}, opts ...QueryOption) (*DatabaseRef, error) {
+	name, err := PushContext(ctxt, r, v, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return r.Ref(name), nil
+}
+
+// Update updates the values stored at Firebase database ref r to v.
+func Update(r *DatabaseRef, v interface{}, opts ...QueryOption) error {
+	return UpdateContext(context.Background(), r, v, opts...)
+}
+
+// UpdateContext updates the values stored at Firebase database ref r to v,
+// using the provided context.
+func UpdateContext(ctxt context.Context, r *DatabaseRef, v interface{}, opts ...QueryOption) error {
+	return DoContext(ctxt, OpTypeUpdate, r, v, nil, opts...)
+}
+
+// UpdateReader updates the values stored at Firebase database ref r to the
+// JSON-encoded value read from src, streaming src as the request body (with
+// chunked encoding) instead of reading it into memory.
+//
+// As src is streamed, the request is not retried. An error reading from src
+// is returned as the error.
+func UpdateReader(r *DatabaseRef, src io.Reader, opts ...QueryOption) error {
+	return UpdateReaderContext(context.Background(), r, src, opts...)
+}
+
+// UpdateReaderContext updates the values stored at Firebase database ref r to
+// the JSON-encoded value read from src, streaming src as the request body,
+// using the provided context.
+func UpdateReaderContext(ctxt context.Context, r *DatabaseRef, src io.Reader, opts ...QueryOption) error {
+	return DoContext(ctxt, OpTypeUpdate, r, &streamBody{Reader: src, op: "read"}, nil, append(opts[:len(opts):len(opts)], PrintSilent)...)
+}
+
+// Remove removes the values stored at Firebase database ref r.
+func Remove(r *DatabaseRef, opts ...QueryOption) error {
+	return RemoveContext(context.Background(), r, opts...)
+}
+
+// RemoveContext removes the values stored at Firebase database ref r, using
+// the provided context.
+func RemoveContext(ctxt context.Context, r *DatabaseRef, opts ...QueryOption) error {
+	return DoContext(ctxt, OpTypeRemove, r, nil, nil, opts...)
+}
+
+// GetShallowKeys retrieves the keys of the children stored at Firebase
+// database ref r, sorted in ascending order, without retrieving the children's
+// values.
+//
+// An empty slice is returned when no value is stored at r. ErrLeafNode will be
+// returned when r is a leaf node (ie, a primitive value with no children).
+func GetShallowKeys(r *DatabaseRef, opts ...QueryOption) ([]string, error) {
+	d, err := GetRaw(r, append([]QueryOption{Shallow}, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// non-existent node
+	if isNull(d) {
+		return []string{}, nil
+	}
+
+	// leaf node
+	if d[0] != '{' {
+		return nil, ErrLeafNode
+	}
+
+	// decode keys
+	var m map[string]interface{}
+	err = json.Unmarshal(d, &m)
+	if err != nil {
+		return nil, &Error{
+			Err: fmt.Sprintf("could not unmarshal json: %v", err),
+		}
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// CountChildren counts the children stored at Firebase database ref r,
+// without retrieving the children's values.
+//
+// The keys of the shallow response are counted as the response is read, so
+// that the response for a node with many children is not held in memory.
+//
+// 0 is returned when no value is stored at r. ErrLeafNode will be returned
+// when r is a leaf node (ie, a primitive value with no children).
+func CountChildren(r *DatabaseRef, opts ...QueryOption) (int, error) {
+	return CountChildrenContext(context.Background(), r, opts...)
+}
+
+// CountChildrenContext counts the children stored at Firebase database ref r,
+// using the provided context.
+func CountChildrenContext(ctxt context.Context, r *DatabaseRef, opts ...QueryOption) (int, error) {
+	pr, pw := io.Pipe()
+
+	type result struct {
+		n   int
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		n, err := countChildren(pr)
+		if err != nil {
+			pr.CloseWithError(err)
+		}
+		io.Copy(ioutil.Discard, pr)
+		done <- result{n, err}
+	}()
+
+	err := DoContext(ctxt, OpTypeGet, r, nil, pw, append([]QueryOption{Shallow}, opts...)...)
+	pw.CloseWithError(err)
+
+	// the leaf node error aborts the request
+	res := <-done
+	switch {
+	case res.err == ErrLeafNode:
+		return 0, res.err
+	case err != nil:
+		return 0, err
+	case res.err != nil:
+		return 0, res.err
+	}
+
+	return res.n, nil
+}
+
+// countChildren counts the children of the JSON object (or array) read from
+// rd. Null array elements (ie, missing children) are not counted.
+func countChildren(rd io.Reader) (int, error) {
+	dec := json.NewDecoder(rd)
+	tok, err := dec.Token()
+	switch {
+	// non-existent node
+	case err == io.EOF || (err == nil && tok == nil):
+		return 0, nil
+
+	case err != nil:
+		return 0, &Error{
+			Err: fmt.Sprintf("could not unmarshal json: %v", err),
+			err: err,
+		}
+	}
+
+	// leaf node
+	delim, ok := tok.(json.Delim)
+	if !ok || (delim != '{' && delim != '[') {
+		return 0, ErrLeafNode
+	}
+
+	var n int
+	for dec.More() {
+		if delim == '{' {
+			if _, err = dec.Token(); err != nil {
+				return 0, &Error{
+					Err: fmt.Sprintf("could not unmarshal json: %v", err),
+					err: err,
+				}
+			}
+		}
+
+		var v json.RawMessage
+		if err = dec.Decode(&v); err != nil {
+			return 0, &Error{
+				Err: fmt.Sprintf("could not unmarshal json: %v", err),
+				err: err,
+			}
+		}
+
+		if delim == '{' || !isNull(v) {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// KeyedValue is a child key and its JSON-encoded value, as returned by
+// GetOrdered.
+type KeyedValue struct {
+	Key   string
+	Value json.RawMessage
+}
+
+// GetOrdered retrieves the children stored at Firebase database ref r,
+// retaining the order of the children as sent by the server.
+//
+// Firebase only orders the children of a REST response when a query (ie,
+// OrderBy with StartAt, LimitToLast, etc) is passed, otherwise the order of
+// the returned children is unspecified.
+//
+// An empty slice is returned when no value is stored at r. ErrLeafNode will be
+// returned when r is a leaf node (ie, a primitive value with no children).
+func GetOrdered(r *DatabaseRef, opts ...QueryOption) ([]KeyedValue, error) {
+	return GetOrderedContext(context.Background(), r, opts...)
+}
+
+// GetOrderedContext retrieves the children stored at Firebase database ref r,
+// retaining the order of the children as sent by the server, using the
+// provided context.
+func GetOrderedContext(ctxt context.Context, r *DatabaseRef, opts ...QueryOption) ([]KeyedValue, error) {
+	d, err := GetRawContext(ctxt, r, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeOrdered(d)
+}
+
+// GetChildren retrieves the children stored at Firebase database ref r,
+// returning each child's JSON-encoded value keyed by the child's key, without
+// decoding the children's values.
+//
+// An empty map is returned when no value is stored at r. ErrLeafNode will be
+// returned when r is a leaf node (ie, a primitive value with no children).
+func GetChildren(r *DatabaseRef, opts ...QueryOption) (map[string]json.RawMessage, error) {
+	return GetChildrenContext(context.Background(), r, opts...)
+}
+
+// GetChildrenContext retrieves the children stored at Firebase database ref
+// r, returning each child's JSON-encoded value keyed by the child's key, using
+// the provided context.
+func GetChildrenContext(ctxt context.Context, r *DatabaseRef, opts ...QueryOption) (map[string]json.RawMessage, error) {
+	kvs, err := GetOrderedContext(ctxt, r, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]json.RawMessage, len(kvs))
+	for _, kv := range kvs {
+		m[kv.Key] = kv.Value
+	}
+
+	return m, nil
+}
+
+// decodeOrdered decodes the children of the JSON object (or array) buf in the
+// order they are encoded. Null array elements (ie, missing children) are
+// skipped.
+func decodeOrdered(buf []byte) ([]KeyedValue, error) {
+	// non-existent node
+	if isNull(buf) {
+		return []KeyedValue{}, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, &Error{
+			Err: fmt.Sprintf("could not unmarshal json: %v", err),
+		}
+	}
+
+	// leaf node
+	delim, ok := tok.(json.Delim)
+	if !ok || (delim != '{' && delim != '[') {
+		return nil, ErrLeafNode
+	}
+
+	kvs := []KeyedValue{}
+	for i := 0; dec.More(); i++ {
+		key := strconv.Itoa(i)
+		if delim == '{' {
+			tok, err = dec.Token()
+			if err != nil {
+				return nil, &Error{
+					Err: fmt.Sprintf("could not unmarshal json: %v", err),
+				}
+			}
+			key = tok.(string)
+		}
+
+		var v json.RawMessage
+		err = dec.Decode(&v)
+		if err != nil {
+			return nil, &Error{
+				Err: fmt.Sprintf("could not unmarshal json: %v", err),
+			}
+		}
+
+		if delim == '[' && isNull(v) {
+			continue
+		}
+
+		kvs = append(kvs, KeyedValue{Key: key, Value: v})
+	}
+
+	return kvs, nil
+}
+
+// GetExport retrieves the values stored at Firebase database ref r in the
+// export format (ie, including ".priority" metadata), returning the raw JSON
+// payload unmodified.
+func GetExport(r *DatabaseRef, opts ...QueryOption) (json.RawMessage, error) {
+	return GetRaw(r, append([]QueryOption{FormatExport}, opts...)...)
+}
+
+// SetWithPriority stores values v at Firebase database ref r along with the
+// priority. The priority must be a string, a number, or nil (removing the
+// priority).
+func SetWithPriority(r *DatabaseRef, v, priority interface{}, opts ...QueryOption) error {
+	return SetWithPriorityContext(context.Background(), r, v, priority, opts...)
+}
+
+// SetWithPriorityContext stores values v at Firebase database ref r along
+// with the priority, using the provided context.
+func SetWithPriorityContext(ctxt context.Context, r *DatabaseRef, v, priority interface{}, opts ...QueryOption) error {
+	if err := checkPriority(priority); err != nil {
+		return err
+	}
+
+	// unwrap prioritized values
+	if p, ok := v.(prioritized); ok {
+		v = p.prioritizedValue()
+	}
+
+	return SetContext(ctxt, r, map[string]interface{}{
+		".value":    v,
+		".priority": priority,
+	}, opts...)
+}
+
+// checkPriority returns an error when priority is not a valid Firebase
+// priority (ie, a string, a number, or nil).
+func checkPriority(priority interface{}) error {
+	switch priority.(type) {
+	case nil, string, json.Number,
+		int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64,
+		float32, float64:
+		return nil
+	}
+	return &Error{
+		Err: fmt.Sprintf("invalid priority type %T (must be a string, number or nil)", priority),
+	}
+}
+
+// prioritized is the interface for values carrying their priority (see
+// Prioritized).
+type prioritized interface {
+	prioritizedValue() interface{}
+}
+
+// prioritizedDecoder is the interface for prioritized values decoded with a
+// database ref's decoder (see Prioritized).
+type prioritizedDecoder interface {
+	decodePrioritized(buf []byte, dc decoder) error
+}
+
+// priorityOpts returns opts with the FormatExport query option prepended when
+// d is a prioritized value, so that the priority is retrieved along with the
+// value.
+func priorityOpts(d interface{}, opts []QueryOption) []QueryOption {
+	if _, ok := d.(prioritized); !ok {
+		return opts
+	}
+	return append([]QueryOption{FormatExport}, opts...)
+}
+
+// GetPriority retrieves the priority of the values stored at Firebase
+// database ref r, returning nil when no priority has been set. Numeric
+// priorities are returned as json.Number values.
+func GetPriority(r *DatabaseRef, opts ...QueryOption) (interface{}, error) {
+	return GetPriorityContext(context.Background(), r, opts...)
+}
+
+// GetPriorityContext retrieves the priority of the values stored at Firebase
+// database ref r, using the provided context.
+func GetPriorityContext(ctxt context.Context, r *DatabaseRef, opts ...QueryOption) (interface{}, error) {
+	var priority interface{}
+	err := GetContext(ctxt, r.Ref(".priority"), &priority, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return priority, nil
+}
+
+// SetRules sets the security rules for Firebase database ref r. v can be a
+// *Rules, or any other value that can be marshaled to a rules document.
+func SetRules(r *DatabaseRef, v interface{}) error {
+	// encode with encoding/json, regardless of the codec
+	switch v.(type) {
+	case []byte, io.Reader:
+	default:
+		buf, err := json.Marshal(v)
+		if err != nil {
+			return &Error{
+				Err:    fmt.Sprintf("could not marshal json: %v", err),
+				Method: string(OpTypeSet),
+				Path:   r.Ref("/.settings/rules").URL().Path,
+			}
+		}
+		v = buf
+	}
+	return Do(OpTypeSet, r.Ref("/.settings/rules"), v, nil)
+}
+
+// SetRulesJSON sets the JSON-encoded security rules for Firebase database ref
+// r. The rules are checked for well-formedness, and sent to the server
+// unmodified.
+//
+// As with the Firebase console, the rules may contain // and /* */ comments,
+// which are preserved.
+//
+// When passed the RulesValidate option, the rules are validated prior to being
+// applied (see ValidateRulesJSON).
+func SetRulesJSON(r *DatabaseRef, buf []byte, opts ...RulesOption) error {
+	var err error
+
+	// apply opts
+	rc := &rulesConfig{}
+	for _, o := range opts {
+		err = o(rc)
+		if err != nil {
+			return err
+		}
+	}
+
+	// check (ignoring comments)
+	stripped, err := stripComments(buf)
+	if err != nil {
+		return &Error{
+			Err: fmt.Sprintf("could not decode json: %v", err),
+		}
+	}
+	if !json.Valid(stripped) {
+		return &Error{
+			Err: "could not decode json: invalid rules json",
+		}
+	}
+
+	// validate
+	if rc.validate {
+		err = ValidateRulesJSON(r, buf)
+		if err != nil {
+			return err
+		}
+	}
+
+	return SetRulesReader(r, bytes.NewReader(buf))
+}
+
+// SetRulesReader sets the JSON-encoded security rules read from src for
+// Firebase database ref r, streaming src directly to the server.
+func SetRulesReader(r *DatabaseRef, src io.Reader) error {
+	return Do(OpTypeSet, r.Ref("/.settings/rules"), src, nil, jsonContentType)
+}
+
+// GetRulesJSON retrieves the security rules for Firebase database ref r,
+// returned unmodified (ie, including any comments).
+func GetRulesJSON(r *DatabaseRef) ([]byte, error) {
+	var buf bytes.Buffer
+	err := Do(OpTypeGet, r.Ref("/.settings/rules"), nil, &buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DatabaseRef is a Firebase database reference.
+type DatabaseRef struct {
+	rw sync.RWMutex
+
+	url       *url.URL
+	transport http.RoundTripper
+
+	// client is the HTTP client used as the basis for requests.
+	client *http.Client
+
+	// doer executes requests in place of the client, when not nil.
+	doer Doer
+
+	// disableCompression disables requesting gzip compressed responses.
+	disableCompression bool
+
+	// compressMin is the minimum size of request bodies to gzip compress, or 0
+	// when request bodies are not compressed.
+	compressMin int
+
+	// userAgent is the User-Agent sent with requests.
+	userAgent string
+
+	// headers are the default headers sent with requests.
+	headers http.Header
+
+	// reqLog is the request logging configuration.
+	reqLog requestLog
+
+	// metrics is the metrics hook for requests.
+	metrics MetricsHook
+
+	// requestIDHeader is the HTTP header for request ids generated by
+	// requestIDGen.
+	requestIDHeader string
+	requestIDGen    func() string
+
+	// dryRun is the recorder for writes when in dry-run mode.
+	dryRun func(Operation)
+
+	// cache is the read cache, shared by the refs created from the ref.
+	cache *readCache
+
+	// timeOffset is the cached server time offset, shared by the refs
+	// created from the ref.
+	timeOffset *serverTimeOffset
+
+	// maxResponseBytes is the maximum size of response bodies and streamed
+	// events, or 0 when unlimited.
+	maxResponseBytes int64
+
+	// source is the oauth2 token source.
+	source oauth2.TokenSource
+
+	// tokenSkew is the time prior to a token's expiry to refresh the token.
+	tokenSkew time.Duration
+
+	// secret is the legacy database secret.
+	secret string
+
+	// authTransport is how oauth2 tokens are attached to requests.
+	authTransport AuthTransportType
+
+	// retryOpts are the retry options for requests.
+	retryOpts retryOptions
+
+	// emulatorHost is the host of the Firebase database emulator.
+	emulatorHost string
+
+	// defaultCreds loads the application default credentials, and is used
+	// when no other credentials were supplied.
+	defaultCreds func(*DatabaseRef) error
+
+	queryOpts []QueryOption
+
+	// timeout is the default client-side timeout for requests.
+	timeout time.Duration
+
+	// decodeNumbers is the default number decoding for responses.
+	decodeNumbers NumberDecoding
+
+	// strictDecode disallows unknown fields when decoding responses.
+	strictDecode bool
+
+	// codec encodes and decodes JSON in place of encoding/json, when not nil.
+	codec Codec
+
+	watchBufLen int
+	watchOpts   watchOptions
+}
+
+// NewDatabaseRef creates a new Firebase base database ref using the supplied
+// options.
+func NewDatabaseRef(opts ...Option) (*DatabaseRef, error) {
+	var err error
+
+	// create client
+	r := &DatabaseRef{
+		tokenSkew: DefaultTokenRefreshSkew,
+		userAgent: DefaultUserAgent,
+		retryOpts: retryOptions{
+			retryAfterMax: DefaultRetryAfterMax,
+		},
+		timeOffset:  new(serverTimeOffset),
+		watchBufLen: DefaultWatchBuffer,
+		watchOpts: watchOptions{
+			minBackoff: DefaultWatchMinBackoff,
+			maxBackoff: DefaultWatchMaxBackoff,
+		},
+	}
+
+	// apply opts
+	for _, o := range opts {
+		err = o(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// use the emulator host from the environment
+	if r.emulatorHost == "" {
+		r.emulatorHost = os.Getenv(EmulatorHostEnv)
+	}
+
+	// use application default credentials when no other credentials were
+	// supplied
+	if r.defaultCreds != nil && !r.hasCredentials() && r.emulatorHost == "" {
+		err = r.defaultCreds(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// check url was set
+	if r.url == nil {
+		return nil, errors.New("no firebase url specified")
+	}
+
+	// check auth
+	err = r.checkAuth()
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+const (
+	// EmulatorHostEnv is the environment variable containing the host (ie,
+	// localhost:9000) of the Firebase database emulator.
+	EmulatorHostEnv = "FIREBASE_DATABASE_EMULATOR_HOST"
+
+	// emulatorToken is the bearer token granting owner access to the Firebase
+	// database emulator.
+	emulatorToken = "owner"
+)
+
+// emulatorNamespace returns the emulator namespace (ie, the database name)
+// for the Firebase database host.
+func emulatorNamespace(host string) string {
+	if i := strings.Index(host, "."); i != -1 {
+		return host[:i]
+	}
+	return host
+}
+
+// firebaseHosts are the host suffixes of Firebase databases.
+var firebaseHosts = []string{".firebaseio.com", ".firebasedatabase.app"}
+
+// NewDatabaseRefFromURL creates a new Firebase database ref for the full
+// Firebase URL rawurl (ie, https://<project>.firebaseio.com/users/abc?orderBy="age"),
+// using the supplied options, returning the ref for the URL's path and the
+// query options corresponding to the URL's query parameters.
+//
+// The recognized query parameters are orderBy, startAt, endAt, equalTo,
+// limitToFirst, limitToLast, shallow, and print. An error is returned for any
+// other query parameter.
+func NewDatabaseRefFromURL(rawurl string, opts ...Option) (*DatabaseRef, []QueryOption, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not parse url: %v", err)
+	}
+
+	// check host
+	valid := false
+	for _, h := range firebaseHosts {
+		if strings.HasSuffix(u.Hostname(), h) && len(u.Hostname()) > len(h) {
+			valid = true
+		}
+	}
+	if u.Scheme != "https" || !valid {
+		return nil, nil, fmt.Errorf("invalid firebase url %q", u.Scheme+"://"+u.Host)
+	}
+
+	// check path
+	path := strings.TrimSuffix(u.Path, ".json")
+	if err = checkPath(path); err != nil {
+		return nil, nil, err
+	}
+
+	// convert query params
+	var queryOpts []QueryOption
+	for k, vals := range u.Query() {
+		for _, v := range vals {
+			o, err := urlQueryOption(k, v)
+			if err != nil {
+				return nil, nil, err
+			}
+			queryOpts = append(queryOpts, o)
+		}
+	}
+
+	r, err := NewDatabaseRef(append([]Option{URL("https://" + u.Host + "/")}, opts...)...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return r.Ref(path), queryOpts, nil
+}
+
+// urlQueryOption returns the query option for the URL query parameter k with
+// value v.
+func urlQueryOption(k, v string) (QueryOption, error) {
+	// decode json encoded value
+	decode := func() (interface{}, error) {
+		var val interface{}
+		dec := json.NewDecoder(strings.NewReader(v))
+		dec.UseNumber()
+		if err := dec.Decode(&val); err != nil {
+			return nil, fmt.Errorf("invalid %s value %q", k, v)
+		}
+		return val, nil
+	}
+
+	switch k {
+	case "orderBy":
+		val, err := decode()
+		if err != nil {
+			return nil, err
+		}
+		field, ok := val.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid %s value %q", k, v)
+		}
+		return OrderBy(field), nil
+
+	case "startAt", "endAt", "equalTo":
+		val, err := decode()
+		if err != nil {
+			return nil, err
+		}
+		switch k {
+		case "startAt":
+			return StartAt(val), nil
+		case "endAt":
+			return EndAt(val), nil
+		}
+		return EqualTo(val), nil
 
-	err := Do(OpTypePush, r, v, &res, opts...)
-	if err != nil {
-		return "", err
+	case "limitToFirst", "limitToLast":
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid %s value %q", k, v)
+		}
+		if k == "limitToFirst" {
+			return LimitToFirst(n), nil
+		}
+		return LimitToLast(n), nil
+
+	case "shallow":
+		if v != "true" {
+			return nil, fmt.Errorf("invalid %s value %q", k, v)
+		}
+		return Shallow, nil
+
+	case "print":
+		switch v {
+		case "pretty":
+			return PrintPretty, nil
+		case "silent":
+			return PrintSilent, nil
+		}
+		return nil, fmt.Errorf("invalid %s value %q", k, v)
 	}
 
-	return res.Name, nil
+	return nil, fmt.Errorf("unknown query parameter %q", k)
 }
 
-// Update updates the values stored at Firebase database ref r to v.
-func Update(r *DatabaseRef, v interface{}, opts ...QueryOption) error {
-	return Do(OpTypeUpdate, r, v, nil, opts...)
+// checkAuth checks that only one authentication method is configured for the
+// database ref.
+func (r *DatabaseRef) checkAuth() error {
+	if r.secret != "" && r.source != nil {
+		return errors.New("database secret cannot be combined with oauth2 credentials")
+	}
+	return nil
 }
 
-// Remove removes the values stored at Firebase database ref r.
-func Remove(r *DatabaseRef, opts ...QueryOption) error {
-	return Do(OpTypeRemove, r, nil, nil, opts...)
+// hasCredentials returns true when credentials have been supplied for the
+// database ref.
+func (r *DatabaseRef) hasCredentials() bool {
+	if r.secret != "" || r.source != nil {
+		return true
+	}
+	_, ok := r.transport.(*oauth2.Transport)
+	return ok
 }
 
-// SetRules sets the security rules for Firebase database ref r.
-func SetRules(r *DatabaseRef, v interface{}) error {
-	return Do(OpTypeSet, r.Ref("/.settings/rules"), v, nil)
+// tokenSource wraps an oauth2.TokenSource, converting token retrieval errors
+// into an *Error that wraps the original error.
+type tokenSource struct {
+	source oauth2.TokenSource
 }
 
-// SetRulesJSON sets the JSON-encoded security rules for Firebase database ref
-// r.
-func SetRulesJSON(r *DatabaseRef, buf []byte) error {
-	var err error
-	var v interface{}
-
-	// decode
-	d := json.NewDecoder(bytes.NewReader(buf))
-	d.UseNumber()
-	err = d.Decode(&v)
+// Token satisfies the oauth2.TokenSource interface.
+func (ts tokenSource) Token() (*oauth2.Token, error) {
+	tok, err := ts.source.Token()
 	if err != nil {
-		return &Error{
-			Err: fmt.Sprintf("could not decode json: %v", err),
+		return nil, &Error{
+			Err: fmt.Sprintf("could not retrieve oauth2 token: %v", err),
+			err: err,
 		}
 	}
+	return tok, nil
+}
 
-	// encode
-	var rules bytes.Buffer
-	e := json.NewEncoder(&rules)
-	e.SetEscapeHTML(false)
-	e.SetIndent("", "  ")
-	err = e.Encode(&v)
-	if err != nil {
-		return &Error{
-			Err: fmt.Sprintf("could not encode json: %v", err),
-		}
-	}
+// Doer is the interface for executing HTTP requests, satisfied by
+// *http.Client (see the HTTPDoer option).
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
 
-	return Do(OpTypeSet, r.Ref("/.settings/rules"), rules.Bytes(), nil)
+// Codec is the interface for encoding and decoding JSON in place of
+// encoding/json (see the JSONCodec option).
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
 }
 
-// GetRulesJSON retrieves the security rules for Firebase database ref r.
-func GetRulesJSON(r *DatabaseRef) ([]byte, error) {
-	var d json.RawMessage
-	err := Do(OpTypeGet, r.Ref("/.settings/rules"), nil, &d)
-	if err != nil {
-		return nil, err
+// httpClient returns a Doer suitable for use with Firebase, which is either
+// the database ref's doer, or a http.Client.
+func (r *DatabaseRef) httpClient() (Doer, error) {
+	r.rw.RLock()
+	defer r.rw.RUnlock()
+
+	if r.doer != nil {
+		// copy client, as watches clear the client's timeout
+		if c, ok := r.doer.(*http.Client); ok {
+			client := *c
+			return &client, nil
+		}
+		return r.doer, nil
 	}
-	return []byte(d), nil
-}
 
-// DatabaseRef is a Firebase database reference.
-type DatabaseRef struct {
-	rw sync.RWMutex
+	transport := r.transport
 
-	url       *url.URL
-	transport http.RoundTripper
+	// set oauth2 transport
+	switch {
+	case r.emulatorHost != "":
+		transport = &oauth2.Transport{
+			Source: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: emulatorToken}),
+			Base:   transport,
+		}
 
-	// source is the oauth2 token source.
-	source oauth2.TokenSource
+	case r.source != nil && r.authTransport == AuthTransportHeader:
+		transport = &oauth2.Transport{
+			Source: tokenSource{r.source},
+			Base:   transport,
+		}
+	}
 
-	queryOpts []QueryOption
+	// copy client
+	client := new(http.Client)
+	if r.client != nil {
+		*client = *r.client
+	}
+	client.Transport = transport
 
-	watchBufLen int
+	return client, nil
 }
 
-// NewDatabaseRef creates a new Firebase base database ref using the supplied
-// options.
-func NewDatabaseRef(opts ...Option) (*DatabaseRef, error) {
+// buildQuery builds the Query for the Firebase database ref by applying the
+// database ref's default query options followed by opts.
+func (r *DatabaseRef) buildQuery(opts ...QueryOption) (*Query, error) {
 	var err error
 
-	// create client
-	r := &DatabaseRef{
-		watchBufLen: DefaultWatchBuffer,
+	r.rw.RLock()
+	q := &Query{
+		Values:  make(url.Values),
+		Header:  make(http.Header),
+		Timeout: r.timeout,
+		Numbers: r.decodeNumbers,
+		Strict:  r.strictDecode,
+	}
+	if len(r.queryOpts) > 0 {
+		// copied, so that opts are never appended to the ref's slice
+		opts = append(append(make([]QueryOption, 0, len(r.queryOpts)+len(opts)), r.queryOpts...), opts...)
 	}
+	r.rw.RUnlock()
 
-	// apply opts
 	for _, o := range opts {
-		err = o(r)
+		err = o(q)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	// check url was set
-	if r.url == nil {
-		return nil, errors.New("no firebase url specified")
+	err = q.validate()
+	if err != nil {
+		return nil, err
 	}
 
-	return r, nil
+	return q, nil
 }
 
-// httpClient returns a http.Client suitable for use with Firebase.
-func (r *DatabaseRef) httpClient() (*http.Client, error) {
+// decoder returns the decoder for the responses to requests made with the
+// Firebase database ref and query opts.
+func (r *DatabaseRef) decoder(opts ...QueryOption) decoder {
 	r.rw.RLock()
-	defer r.rw.RUnlock()
+	dc := decoder{numbers: r.decodeNumbers, strict: r.strictDecode, codec: r.codec}
+	hasQueryOpts := len(r.queryOpts) != 0
+	r.rw.RUnlock()
 
-	transport := r.transport
+	if len(opts) == 0 && !hasQueryOpts {
+		return dc
+	}
 
-	// set oauth2 transport
-	if r.source != nil {
-		transport = &oauth2.Transport{
-			Source: r.source,
-			Base:   transport,
-		}
+	// invalid query options are reported when creating the request
+	q, err := r.buildQuery(opts...)
+	if err != nil {
+		return dc
 	}
 
-	return &http.Client{
-		Transport: transport,
-	}, nil
+	return decoder{numbers: q.Numbers, strict: q.Strict, codec: dc.codec}
 }
 
 // createRequest creates a http.Request for the Firebase database ref with
-// method, body, and query opts.
-func (r *DatabaseRef) createRequest(method string, body io.Reader, opts ...QueryOption) (*http.Request, error) {
+// context, method, body, and query opts.
+//
+// The returned cancel func releases the resources associated with the
+// request's timeout (if any), and must be called once the request is done.
+func (r *DatabaseRef) createRequest(ctxt context.Context, method string, body io.Reader, opts ...QueryOption) (*http.Request, context.CancelFunc, error) {
 	var err error
 
-	// build url
-	u := r.URL().String() + ".json"
-
-	if len(r.queryOpts) > 0 {
-		opts = append(r.queryOpts, opts...)
+	// build url (escaping each path segment)
+	r.rw.RLock()
+	base, emulatorHost, headers := *r.url, r.emulatorHost, r.headers
+	r.rw.RUnlock()
+	path := base.Path
+	base.Path, base.RawPath, base.RawQuery, base.Fragment = "", "", "", ""
+	if emulatorHost != "" {
+		base = url.URL{Scheme: "http", Host: emulatorHost}
 	}
+	u := base.String() + escapePath(path) + ".json"
 
 	// build query params
-	if len(opts) > 0 {
-		v := make(url.Values)
-		for _, o := range opts {
-			err = o(v)
-			if err != nil {
-				return nil, err
-			}
-		}
+	q, err := r.buildQuery(opts...)
+	if err != nil {
+		return nil, nil, err
+	}
 
-		if vstr := v.Encode(); vstr != "" {
-			u = u + "?" + vstr
-		}
+	// add auth (after query options, so that it cannot be overwritten)
+	r.rw.RLock()
+	secret := r.secret
+	err = r.checkAuth()
+	r.rw.RUnlock()
+	if err != nil {
+		return nil, nil, err
+	}
+	switch {
+	case emulatorHost != "":
+		q.Values.Del("auth")
+		q.Values.Set("ns", emulatorNamespace(r.URL().Hostname()))
+	case secret != "":
+		q.Values.Set("auth", secret)
+	case q.Values.Get("auth") != "" && q.Values.Get("auth_variable_override") != "":
+		// a user supplied auth (ie, an id token) cannot be overridden
+		return nil, nil, errors.New("auth_variable_override requires admin credentials")
+	}
+
+	if vstr := q.Values.Encode(); vstr != "" {
+		u = u + "?" + vstr
+	}
+
+	// apply timeout
+	cancel := context.CancelFunc(func() {})
+	if q.Timeout > 0 {
+		ctxt, cancel = context.WithTimeout(ctxt, q.Timeout)
 	}
 
 	// create request
-	req, err := http.NewRequest(method, u, body)
+	req, err := http.NewRequestWithContext(ctxt, method, u, body)
 	if err != nil {
-		return nil, err
+		cancel()
+		return nil, nil, err
+	}
+
+	// set headers (query headers override the default headers)
+	for k, v := range headers {
+		req.Header[k] = append([]string(nil), v...)
+	}
+	for k, v := range q.Header {
+		req.Header[k] = v
 	}
 
 	// substitute + on raw path
@@ -285,35 +1681,90 @@ func (r *DatabaseRef) createRequest(method string, body io.Reader, opts ...Query
 		req.URL.RawPath = strings.Replace(req.URL.Path, "+", "%2B", -1)
 	}
 
-	return req, nil
+	return req, cancel, nil
 }
 
-// clientAndRequest creates a *http.Client and *http.Request for the Firebase
-// ref.
-func (r *DatabaseRef) clientAndRequest(method string, body io.Reader, opts ...QueryOption) (*http.Client, *http.Request, error) {
+// clientAndRequest creates a Doer and *http.Request for the Firebase ref,
+// bound to the provided context.
+//
+// The returned cancel func must be called once the request is done.
+func (r *DatabaseRef) clientAndRequest(ctxt context.Context, method string, body io.Reader, opts ...QueryOption) (Doer, *http.Request, context.CancelFunc, error) {
 	var err error
 
 	// get client
 	client, err := r.httpClient()
 	if err != nil {
-		return nil, nil, &Error{
+		return nil, nil, nil, &Error{
 			Err: fmt.Sprintf("could not create client: %v", err),
 		}
 	}
 
 	// create request
-	req, err := r.createRequest(method, body, opts...)
+	req, cancel, err := r.createRequest(ctxt, method, body, opts...)
 	if err != nil {
-		return nil, nil, &Error{
+		return nil, nil, nil, &Error{
 			Err: fmt.Sprintf("could not create request: %v", err),
 		}
 	}
 
-	return client, req, nil
+	r.rw.RLock()
+	source, authTransport, emulatorHost := r.source, r.authTransport, r.emulatorHost
+	disableCompression, userAgent, doer := r.disableCompression, r.userAgent, r.doer
+	r.rw.RUnlock()
+
+	// set user agent
+	if userAgent != "" && req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+
+	// request compressed responses (decompressed by checkServerError), or
+	// explicitly request uncompressed responses, as otherwise the transport
+	// transparently requests compression
+	if req.Header.Get("Accept-Encoding") == "" {
+		if disableCompression {
+			req.Header.Set("Accept-Encoding", "identity")
+		} else {
+			req.Header.Set("Accept-Encoding", "gzip")
+		}
+	}
+
+	// add access token (after query options, so that it cannot be overwritten)
+	if source != nil && authTransport == AuthTransportQueryParam && emulatorHost == "" {
+		tok, err := tokenSource{source}.Token()
+		if err != nil {
+			cancel()
+			return nil, nil, nil, err
+		}
+
+		q := req.URL.Query()
+		q.Set("access_token", tok.AccessToken)
+		req.URL.RawQuery = q.Encode()
+	}
+
+	// add authorization header, as a doer bypasses the oauth2 transport
+	if doer != nil {
+		switch {
+		case emulatorHost != "":
+			req.Header.Set("Authorization", "Bearer "+emulatorToken)
+
+		case source != nil && authTransport == AuthTransportHeader:
+			tok, err := tokenSource{source}.Token()
+			if err != nil {
+				cancel()
+				return nil, nil, nil, err
+			}
+			tok.SetAuthHeader(req)
+		}
+	}
+
+	return client, req, cancel, nil
 }
 
 // Ref creates a new Firebase database child ref, locked to the specified path.
 //
+// Ref panics when a segment of path is not a valid key (see IsValidKey). Keys
+// supplied by users should be checked with IsValidKey prior to use.
+//
 // NOTE: any Option passed returning an error will cause this func to panic.
 // Instead if an Option might return an error, then it should be applied after
 // the child ref has been created in the following manner:
@@ -322,46 +1773,207 @@ func (r *DatabaseRef) clientAndRequest(method string, body io.Reader, opts ...Qu
 //     err := SomeOption(child)
 // 	   if err != nil { log.Fatal(err) }
 func (r *DatabaseRef) Ref(path string, opts ...Option) *DatabaseRef {
-	r.rw.RLock()
-	defer r.rw.RUnlock()
-
 	// create new path
-	curpath := r.url.Path
+	curpath := r.path()
 	if !strings.HasSuffix(curpath, "/") {
 		curpath += "/"
 	}
 	path = strings.TrimPrefix(path, "/")
 
+	// check path
+	if err := checkPath(path); err != nil {
+		panic(err)
+	}
+
 	// create child ref
-	c := &DatabaseRef{
+	c := r.withPath(curpath + path)
+
+	// apply opts
+	for _, o := range opts {
+		err := o(c)
+		if err != nil {
+			// options that could error out should not be applied here
+			panic(err)
+		}
+	}
+
+	return c
+}
+
+// withPath creates a new Firebase database ref for path, sharing the client,
+// auth, and default options of the database ref.
+func (r *DatabaseRef) withPath(path string) *DatabaseRef {
+	r.rw.RLock()
+	defer r.rw.RUnlock()
+
+	return &DatabaseRef{
 		url: &url.URL{
 			Scheme: r.url.Scheme,
 			Opaque: r.url.Opaque,
 			User:   r.url.User,
 			Host:   r.url.Host,
-			Path:   curpath + path,
+			Path:   path,
 		},
-		transport:   r.transport,
-		source:      r.source,
-		queryOpts:   r.queryOpts,
-		watchBufLen: r.watchBufLen,
+		transport:          r.transport,
+		client:             r.client,
+		doer:               r.doer,
+		disableCompression: r.disableCompression,
+		compressMin:        r.compressMin,
+		userAgent:          r.userAgent,
+		headers:            r.headers,
+		reqLog:             r.reqLog,
+		metrics:            r.metrics,
+		requestIDHeader:    r.requestIDHeader,
+		requestIDGen:       r.requestIDGen,
+		maxResponseBytes:   r.maxResponseBytes,
+		dryRun:             r.dryRun,
+		cache:              r.cache,
+		timeOffset:         r.timeOffset,
+		source:             r.source,
+		tokenSkew:          r.tokenSkew,
+		secret:             r.secret,
+		authTransport:      r.authTransport,
+		emulatorHost:       r.emulatorHost,
+		retryOpts:          r.retryOpts,
+		queryOpts:          r.queryOpts,
+		timeout:            r.timeout,
+		decodeNumbers:      r.decodeNumbers,
+		strictDecode:       r.strictDecode,
+		codec:              r.codec,
+		watchBufLen:        r.watchBufLen,
+		watchOpts:          r.watchOpts,
 	}
+}
 
-	// apply opts
-	for _, o := range opts {
-		err := o(c)
-		if err != nil {
-			// options that could error out should not be applied here
+// Child creates a new Firebase database child ref for the path segments parts,
+// joined by a single slash (ie, Child("a/", "/b") and Child("a//b") both refer
+// to the child a/b).
+//
+// As with Ref, Child panics when a path segment is not a valid key.
+func (r *DatabaseRef) Child(parts ...string) *DatabaseRef {
+	segs := splitPath(r.path())
+	for _, p := range parts {
+		if err := checkPath(p); err != nil {
 			panic(err)
 		}
+		segs = append(segs, splitPath(p)...)
+	}
+	return r.withPath("/" + strings.Join(segs, "/"))
+}
+
+// Parent returns the parent ref of the Firebase database ref, or nil if the
+// database ref is the root.
+func (r *DatabaseRef) Parent() *DatabaseRef {
+	segs := splitPath(r.path())
+	if len(segs) == 0 {
+		return nil
 	}
+	return r.withPath("/" + strings.Join(segs[:len(segs)-1], "/"))
+}
 
-	return c
+// Root returns the root ref of the Firebase database ref.
+func (r *DatabaseRef) Root() *DatabaseRef {
+	return r.withPath("/")
+}
+
+// Instance returns the root ref of the Firebase database instance name in
+// region (see DatabaseInstance), sharing the client, auth (including the
+// OAuth2 token source), and default options of the database ref.
+func (r *DatabaseRef) Instance(name, region string) (*DatabaseRef, error) {
+	u, err := instanceURL(name, region)
+	if err != nil {
+		return nil, err
+	}
+
+	c := r.withPath("/")
+	c.url = u
+	c.timeOffset = new(serverTimeOffset)
+
+	return c, nil
+}
+
+// Key returns the last path segment of the Firebase database ref, or an empty
+// string if the database ref is the root.
+func (r *DatabaseRef) Key() string {
+	segs := splitPath(r.path())
+	if len(segs) == 0 {
+		return ""
+	}
+	return segs[len(segs)-1]
+}
+
+// specialKeys are the reserved Firebase keys that are valid in a path, but
+// not as a key.
+var specialKeys = []string{".info", ".settings", ".priority", ".value"}
+
+// invalidKeyChars are the characters that cannot be used in a Firebase key.
+const invalidKeyChars = ".$#[]/"
+
+// IsValidKey returns true when s is a valid Firebase key (ie, is not empty,
+// and does not contain any of the characters . $ # [ ] / or an ASCII control
+// character).
+func IsValidKey(s string) bool {
+	return checkKey(s) == nil
+}
+
+// checkKey checks that s is a valid Firebase key.
+func checkKey(s string) error {
+	if s == "" {
+		return &Error{Err: "invalid key: key cannot be empty"}
+	}
+	for _, c := range s {
+		if strings.ContainsRune(invalidKeyChars, c) || c < 0x20 || c == 0x7f {
+			return &Error{
+				Err: fmt.Sprintf("invalid key %q: cannot contain %q", s, c),
+			}
+		}
+	}
+	return nil
+}
+
+// checkPath checks that each segment of path is a valid Firebase key, or one
+// of the special keys (ie, .settings).
+func checkPath(path string) error {
+	for _, seg := range splitPath(path) {
+		if sliceContains(specialKeys, seg) {
+			continue
+		}
+		if err := checkKey(seg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// path returns the path of the Firebase database ref.
+func (r *DatabaseRef) path() string {
+	r.rw.RLock()
+	defer r.rw.RUnlock()
+
+	return r.url.Path
 }
 
-// URL returns the URL for the Firebase database ref.
+// URL returns a copy of the URL for the Firebase database ref, without any
+// auth material (ie, user info, or the auth and access_token query
+// parameters).
 func (r *DatabaseRef) URL() *url.URL {
-	return r.url
+	r.rw.RLock()
+	u := *r.url
+	r.rw.RUnlock()
+	u.User = nil
+	if u.RawQuery != "" {
+		q := u.Query()
+		q.Del("auth")
+		q.Del("access_token")
+		u.RawQuery = q.Encode()
+	}
+	return &u
+}
+
+// String satisfies the fmt.Stringer interface, returning the URL of the
+// Firebase database ref.
+func (r *DatabaseRef) String() string {
+	return r.URL().String()
 }
 
 // Get retrieves the values stored at the Firebase database ref and decodes
@@ -370,27 +1982,198 @@ func (r *DatabaseRef) Get(d interface{}, opts ...QueryOption) error {
 	return Get(r, d, opts...)
 }
 
+// GetContext retrieves the values stored at the Firebase database ref and
+// decodes them into d, using the provided context.
+func (r *DatabaseRef) GetContext(ctxt context.Context, d interface{}, opts ...QueryOption) error {
+	return GetContext(ctxt, r, d, opts...)
+}
+
 // Set stores values v at the Firebase database ref.
 func (r *DatabaseRef) Set(v interface{}, opts ...QueryOption) error {
 	return Set(r, v, opts...)
 }
 
+// GetReader retrieves the values stored at the Firebase database ref,
+// returning the undecoded JSON response body. The caller must close the
+// returned body.
+func (r *DatabaseRef) GetReader(opts ...QueryOption) (io.ReadCloser, error) {
+	return GetReader(r, opts...)
+}
+
+// SetReader stores the JSON-encoded value read from src at the Firebase
+// database ref, streaming src as the request body.
+func (r *DatabaseRef) SetReader(src io.Reader, opts ...QueryOption) error {
+	return SetReader(r, src, opts...)
+}
+
+// SetContext stores values v at the Firebase database ref, using the provided
+// context.
+func (r *DatabaseRef) SetContext(ctxt context.Context, v interface{}, opts ...QueryOption) error {
+	return SetContext(ctxt, r, v, opts...)
+}
+
 // Push pushes values v to the Firebase database ref, returning the name (ID)
 // of the pushed node.
 func (r *DatabaseRef) Push(v interface{}, opts ...QueryOption) (string, error) {
 	return Push(r, v, opts...)
 }
 
+// PushContext pushes values v to the Firebase database ref, returning the
+// name (ID) of the pushed node, using the provided context.
+func (r *DatabaseRef) PushContext(ctxt context.Context, v interface{}, opts ...QueryOption) (string, error) {
+	return PushContext(ctxt, r, v, opts...)
+}
+
+// PushRef pushes values v to the Firebase database ref, returning a child
+// database ref for the pushed node.
+func (r *DatabaseRef) PushRef(v interface{}, opts ...QueryOption) (*DatabaseRef, error) {
+	return PushRef(r, v, opts...)
+}
+
+// PushRefContext pushes values v to the Firebase database ref, returning a
+// child database ref for the pushed node, using the provided context.
+func (r *DatabaseRef) PushRefContext(ctxt context.Context, v interface{}, opts ...QueryOption) (*DatabaseRef, error) {
+	return PushRefContext(ctxt, r, v, opts...)
+}
+
 // Update updates the values stored at the Firebase database ref to v.
 func (r *DatabaseRef) Update(v interface{}, opts ...QueryOption) error {
 	return Update(r, v, opts...)
 }
 
+// UpdateReader updates the values stored at the Firebase database ref to the
+// JSON-encoded value read from src, streaming src as the request body.
+func (r *DatabaseRef) UpdateReader(src io.Reader, opts ...QueryOption) error {
+	return UpdateReader(r, src, opts...)
+}
+
+// UpdateContext updates the values stored at the Firebase database ref to v,
+// using the provided context.
+func (r *DatabaseRef) UpdateContext(ctxt context.Context, v interface{}, opts ...QueryOption) error {
+	return UpdateContext(ctxt, r, v, opts...)
+}
+
 // Remove removes the values stored at the Firebase database ref.
 func (r *DatabaseRef) Remove(opts ...QueryOption) error {
 	return Remove(r, opts...)
 }
 
+// RemoveContext removes the values stored at the Firebase database ref, using
+// the provided context.
+func (r *DatabaseRef) RemoveContext(ctxt context.Context, opts ...QueryOption) error {
+	return RemoveContext(ctxt, r, opts...)
+}
+
+// GetRaw retrieves the values stored at the Firebase database ref, returning
+// the JSON response body verbatim.
+func (r *DatabaseRef) GetRaw(opts ...QueryOption) (json.RawMessage, error) {
+	return GetRaw(r, opts...)
+}
+
+// GetRawContext retrieves the values stored at the Firebase database ref,
+// returning the JSON response body verbatim, using the provided context.
+func (r *DatabaseRef) GetRawContext(ctxt context.Context, opts ...QueryOption) (json.RawMessage, error) {
+	return GetRawContext(ctxt, r, opts...)
+}
+
+// GetStrict retrieves the values stored at the Firebase database ref and
+// decodes them into d, returning ErrNodeNotExists when no value is stored.
+func (r *DatabaseRef) GetStrict(d interface{}, opts ...QueryOption) error {
+	return GetStrict(r, d, opts...)
+}
+
+// GetStrictContext retrieves the values stored at the Firebase database ref
+// and decodes them into d, using the provided context, returning
+// ErrNodeNotExists when no value is stored.
+func (r *DatabaseRef) GetStrictContext(ctxt context.Context, d interface{}, opts ...QueryOption) error {
+	return GetStrictContext(ctxt, r, d, opts...)
+}
+
+// Exists determines if a value is stored at the Firebase database ref.
+func (r *DatabaseRef) Exists(opts ...QueryOption) (bool, error) {
+	return Exists(r, opts...)
+}
+
+// ExistsContext determines if a value is stored at the Firebase database ref,
+// using the provided context.
+func (r *DatabaseRef) ExistsContext(ctxt context.Context, opts ...QueryOption) (bool, error) {
+	return ExistsContext(ctxt, r, opts...)
+}
+
+// GetShallowKeys retrieves the sorted keys of the children stored at the
+// Firebase database ref, without retrieving the children's values.
+func (r *DatabaseRef) GetShallowKeys(opts ...QueryOption) ([]string, error) {
+	return GetShallowKeys(r, opts...)
+}
+
+// CountChildren counts the children stored at the Firebase database ref,
+// without retrieving the children's values.
+func (r *DatabaseRef) CountChildren(opts ...QueryOption) (int, error) {
+	return CountChildren(r, opts...)
+}
+
+// CountChildrenContext counts the children stored at the Firebase database
+// ref, using the provided context.
+func (r *DatabaseRef) CountChildrenContext(ctxt context.Context, opts ...QueryOption) (int, error) {
+	return CountChildrenContext(ctxt, r, opts...)
+}
+
+// GetOrdered retrieves the children stored at the Firebase database ref,
+// retaining the order of the children as sent by the server.
+func (r *DatabaseRef) GetOrdered(opts ...QueryOption) ([]KeyedValue, error) {
+	return GetOrdered(r, opts...)
+}
+
+// GetOrderedContext retrieves the children stored at the Firebase database
+// ref, retaining the order of the children as sent by the server, using the
+// provided context.
+func (r *DatabaseRef) GetOrderedContext(ctxt context.Context, opts ...QueryOption) ([]KeyedValue, error) {
+	return GetOrderedContext(ctxt, r, opts...)
+}
+
+// GetChildren retrieves the children stored at the Firebase database ref,
+// returning each child's JSON-encoded value keyed by the child's key.
+func (r *DatabaseRef) GetChildren(opts ...QueryOption) (map[string]json.RawMessage, error) {
+	return GetChildren(r, opts...)
+}
+
+// GetChildrenContext retrieves the children stored at the Firebase database
+// ref, returning each child's JSON-encoded value keyed by the child's key,
+// using the provided context.
+func (r *DatabaseRef) GetChildrenContext(ctxt context.Context, opts ...QueryOption) (map[string]json.RawMessage, error) {
+	return GetChildrenContext(ctxt, r, opts...)
+}
+
+// GetExport retrieves the values stored at the Firebase database ref in the
+// export format (ie, including ".priority" metadata).
+func (r *DatabaseRef) GetExport(opts ...QueryOption) (json.RawMessage, error) {
+	return GetExport(r, opts...)
+}
+
+// SetWithPriority stores values v at the Firebase database ref along with the
+// priority.
+func (r *DatabaseRef) SetWithPriority(v, priority interface{}, opts ...QueryOption) error {
+	return SetWithPriority(r, v, priority, opts...)
+}
+
+// SetWithPriorityContext stores values v at the Firebase database ref along
+// with the priority, using the provided context.
+func (r *DatabaseRef) SetWithPriorityContext(ctxt context.Context, v, priority interface{}, opts ...QueryOption) error {
+	return SetWithPriorityContext(ctxt, r, v, priority, opts...)
+}
+
+// GetPriority retrieves the priority of the values stored at the Firebase
+// database ref.
+func (r *DatabaseRef) GetPriority(opts ...QueryOption) (interface{}, error) {
+	return GetPriority(r, opts...)
+}
+
+// GetPriorityContext retrieves the priority of the values stored at the
+// Firebase database ref, using the provided context.
+func (r *DatabaseRef) GetPriorityContext(ctxt context.Context, opts ...QueryOption) (interface{}, error) {
+	return GetPriorityContext(ctxt, r, opts...)
+}
+
 // SetRules sets the security rules for the Firebase database ref.
 func (r *DatabaseRef) SetRules(v interface{}) error {
 	return SetRules(r, v)
@@ -398,8 +2181,14 @@ func (r *DatabaseRef) SetRules(v interface{}) error {
 
 // SetRulesJSON sets the JSON-encoded security rules for the Firebase database
 // ref.
-func (r *DatabaseRef) SetRulesJSON(buf []byte) error {
-	return SetRulesJSON(r, buf)
+func (r *DatabaseRef) SetRulesJSON(buf []byte, opts ...RulesOption) error {
+	return SetRulesJSON(r, buf, opts...)
+}
+
+// SetRulesReader sets the JSON-encoded security rules read from src for the
+// Firebase database ref.
+func (r *DatabaseRef) SetRulesReader(src io.Reader) error {
+	return SetRulesReader(r, src)
 }
 
 // GetRulesJSON retrieves the security rules for the Firebase database ref.
@@ -412,6 +2201,8 @@ func (r *DatabaseRef) GetRulesJSON() ([]byte, error) {
 // when the remote connection is closed, or when an error is encountered while
 // reading events from the server.
 //
+// Query options are applied to the watch in the same manner as with Get.
+//
 // NOTE: the Log option will not work with Watch/Listen.
 func (r *DatabaseRef) Watch(ctxt context.Context, opts ...QueryOption) (<-chan *Event, error) {
 	return Watch(r, ctxt, opts...)
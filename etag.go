@@ -0,0 +1,225 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+const (
+	// NullETag is the ETag returned by Firebase for a database ref that does
+	// not exist.
+	NullETag = "null_etag"
+)
+
+// ErrETagMismatch is the error returned when a conditional operation fails
+// because the supplied ETag does not match the current ETag of the database
+// ref.
+var ErrETagMismatch = &Error{Err: "etag mismatch"}
+
+// ETagMismatchError is the error returned when a conditional operation fails
+// because the supplied ETag does not match the current ETag of the database
+// ref. It contains the current ETag and value stored at the database ref, as
+// returned by Firebase, and can be used to retry the operation without another
+// round trip.
+//
+// ETagMismatchError wraps ErrETagMismatch, for use with errors.Is.
+type ETagMismatchError struct {
+	// ETag is the current ETag of the database ref.
+	ETag string
+
+	// Value is the current JSON-encoded value stored at the database ref.
+	Value json.RawMessage
+}
+
+// Error satisfies the error interface.
+func (e *ETagMismatchError) Error() string {
+	return ErrETagMismatch.Error()
+}
+
+// Unwrap returns ErrETagMismatch.
+func (e *ETagMismatchError) Unwrap() error {
+	return ErrETagMismatch
+}
+
+// ifMatchQuery returns a query option that sets the If-Match header to etag.
+func ifMatchQuery(etag string) QueryOption {
+	return func(q *Query) error {
+		q.Header.Set("If-Match", etag)
+		return nil
+	}
+}
+
+// ifNoneMatchQuery returns a query option that sets the If-None-Match header
+// to etag.
+func ifNoneMatchQuery(etag string) QueryOption {
+	return func(q *Query) error {
+		q.Header.Set("If-None-Match", etag)
+		return nil
+	}
+}
+
+// etagQuery is a query option that requests Firebase to return the ETag of
+// the database ref in the response.
+func etagQuery(q *Query) error {
+	q.Header.Set("X-Firebase-ETag", "true")
+	return nil
+}
+
+// etagOf returns the ETag header from the response.
+func etagOf(res *http.Response) string {
+	return res.Header.Get("ETag")
+}
+
+// GetWithETag retrieves the values stored at Firebase database ref r and
+// decodes them into d, returning the ETag of the values for use with
+// conditional writes.
+//
+// When no values are stored at r, the ETag returned is NullETag.
+func GetWithETag(r *DatabaseRef, d interface{}, opts ...QueryOption) (string, error) {
+	return GetWithETagContext(context.Background(), r, d, opts...)
+}
+
+// GetWithETagContext retrieves the values stored at Firebase database ref r
+// and decodes them into d, returning the ETag of the values, using the
+// provided context.
+func GetWithETagContext(ctxt context.Context, r *DatabaseRef, d interface{}, opts ...QueryOption) (string, error) {
+	res, err := doRequest(ctxt, OpTypeGet, r, nil, d, append([]QueryOption{etagQuery}, opts...)...)
+	if err != nil {
+		return "", err
+	}
+	return etagOf(res), nil
+}
+
+// GetIfChanged retrieves the values stored at Firebase database ref r and
+// decodes them into d only when the current ETag of r does not match etag,
+// returning whether or not the values changed, and the current ETag of r.
+//
+// When the values have not changed, d is left untouched.
+func GetIfChanged(r *DatabaseRef, etag string, d interface{}, opts ...QueryOption) (bool, string, error) {
+	return GetIfChangedContext(context.Background(), r, etag, d, opts...)
+}
+
+// GetIfChangedContext retrieves the values stored at Firebase database ref r
+// and decodes them into d only when the current ETag of r does not match
+// etag, using the provided context.
+func GetIfChangedContext(ctxt context.Context, r *DatabaseRef, etag string, d interface{}, opts ...QueryOption) (bool, string, error) {
+	res, err := doRequest(ctxt, OpTypeGet, r, nil, d, append([]QueryOption{etagQuery, ifNoneMatchQuery(etag)}, opts...)...)
+	if err != nil {
+		return false, "", err
+	}
+
+	if res.StatusCode == http.StatusNotModified {
+		if cur := etagOf(res); cur != "" {
+			return false, cur, nil
+		}
+		return false, etag, nil
+	}
+
+	return true, etagOf(res), nil
+}
+
+// SetIfUnchanged stores values v at Firebase database ref r only when the
+// current ETag of r matches etag, returning the new ETag of r.
+//
+// When the ETags do not match, an *ETagMismatchError containing the current
+// ETag and value of r is returned, along with the current ETag.
+func SetIfUnchanged(r *DatabaseRef, etag string, v interface{}, opts ...QueryOption) (string, error) {
+	return SetIfUnchangedContext(context.Background(), r, etag, v, opts...)
+}
+
+// SetIfUnchangedContext stores values v at Firebase database ref r only when
+// the current ETag of r matches etag, returning the new ETag of r, using the
+// provided context.
+func SetIfUnchangedContext(ctxt context.Context, r *DatabaseRef, etag string, v interface{}, opts ...QueryOption) (string, error) {
+	return doIfMatch(ctxt, OpTypeSet, r, etag, v, opts...)
+}
+
+// RemoveIfUnchanged removes the values stored at Firebase database ref r only
+// when the current ETag of r matches etag.
+//
+// When the ETags do not match, an *ETagMismatchError containing the current
+// ETag and value of r is returned. If r no longer exists (ie, its current ETag
+// is NullETag), then the removal is treated as a successful no-op.
+func RemoveIfUnchanged(r *DatabaseRef, etag string, opts ...QueryOption) error {
+	return RemoveIfUnchangedContext(context.Background(), r, etag, opts...)
+}
+
+// RemoveIfUnchangedContext removes the values stored at Firebase database ref
+// r only when the current ETag of r matches etag, using the provided context.
+func RemoveIfUnchangedContext(ctxt context.Context, r *DatabaseRef, etag string, opts ...QueryOption) error {
+	cur, err := doIfMatch(ctxt, OpTypeRemove, r, etag, nil, opts...)
+	if err != nil && errors.Is(err, ErrETagMismatch) && cur == NullETag {
+		return nil
+	}
+	return err
+}
+
+// doIfMatch executes a conditional operation on Firebase database ref r with
+// the If-Match header set to etag, returning the ETag of r after the
+// operation.
+func doIfMatch(ctxt context.Context, op OpType, r *DatabaseRef, etag string, v interface{}, opts ...QueryOption) (string, error) {
+	res, err := doRequest(ctxt, op, r, v, nil, append([]QueryOption{etagQuery, ifMatchQuery(etag)}, opts...)...)
+	if err != nil {
+		var e *ETagMismatchError
+		if errors.As(err, &e) {
+			return e.ETag, err
+		}
+		return "", err
+	}
+	return etagOf(res), nil
+}
+
+// GetWithETag retrieves the values stored at the Firebase database ref and
+// decodes them into d, returning the ETag of the values.
+func (r *DatabaseRef) GetWithETag(d interface{}, opts ...QueryOption) (string, error) {
+	return GetWithETag(r, d, opts...)
+}
+
+// GetWithETagContext retrieves the values stored at the Firebase database ref
+// and decodes them into d, returning the ETag of the values, using the
+// provided context.
+func (r *DatabaseRef) GetWithETagContext(ctxt context.Context, d interface{}, opts ...QueryOption) (string, error) {
+	return GetWithETagContext(ctxt, r, d, opts...)
+}
+
+// GetIfChanged retrieves the values stored at the Firebase database ref and
+// decodes them into d only when the current ETag of the database ref does not
+// match etag.
+func (r *DatabaseRef) GetIfChanged(etag string, d interface{}, opts ...QueryOption) (bool, string, error) {
+	return GetIfChanged(r, etag, d, opts...)
+}
+
+// GetIfChangedContext retrieves the values stored at the Firebase database
+// ref and decodes them into d only when the current ETag of the database ref
+// does not match etag, using the provided context.
+func (r *DatabaseRef) GetIfChangedContext(ctxt context.Context, etag string, d interface{}, opts ...QueryOption) (bool, string, error) {
+	return GetIfChangedContext(ctxt, r, etag, d, opts...)
+}
+
+// SetIfUnchanged stores values v at the Firebase database ref only when the
+// current ETag of the database ref matches etag, returning the new ETag.
+func (r *DatabaseRef) SetIfUnchanged(etag string, v interface{}, opts ...QueryOption) (string, error) {
+	return SetIfUnchanged(r, etag, v, opts...)
+}
+
+// SetIfUnchangedContext stores values v at the Firebase database ref only when
+// the current ETag of the database ref matches etag, returning the new ETag,
+// using the provided context.
+func (r *DatabaseRef) SetIfUnchangedContext(ctxt context.Context, etag string, v interface{}, opts ...QueryOption) (string, error) {
+	return SetIfUnchangedContext(ctxt, r, etag, v, opts...)
+}
+
+// RemoveIfUnchanged removes the values stored at the Firebase database ref
+// only when the current ETag of the database ref matches etag.
+func (r *DatabaseRef) RemoveIfUnchanged(etag string, opts ...QueryOption) error {
+	return RemoveIfUnchanged(r, etag, opts...)
+}
+
+// RemoveIfUnchangedContext removes the values stored at the Firebase database
+// ref only when the current ETag of the database ref matches etag, using the
+// provided context.
+func (r *DatabaseRef) RemoveIfUnchangedContext(ctxt context.Context, etag string, opts ...QueryOption) error {
+	return RemoveIfUnchangedContext(ctxt, r, etag, opts...)
+}
@@ -0,0 +1,37 @@
+package firebase
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetWithETag(t *testing.T) {
+	tests := []struct {
+		body, etag string
+	}{
+		{`"foo"`, "abc123"},
+		{`null`, NullETag},
+	}
+
+	for i, test := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if h := req.Header.Get("X-Firebase-ETag"); h != "true" {
+				t.Errorf("test %d expected X-Firebase-ETag: true, got: %q", i, h)
+			}
+			w.Header().Set("ETag", test.etag)
+			w.Write([]byte(test.body))
+		}))
+
+		var v interface{}
+		etag, err := newTestRef(t, srv).GetWithETag(&v)
+		srv.Close()
+		if err != nil {
+			t.Errorf("test %d expected no error, got: %v", i, err)
+			continue
+		}
+		if etag != test.etag {
+			t.Errorf("test %d expected etag %q, got: %q", i, test.etag, etag)
+		}
+	}
+}
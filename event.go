@@ -1,6 +1,12 @@
 package firebase
 
-import "fmt"
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
 
 // EventType is a Firebase event type.
 type EventType string
@@ -37,6 +43,11 @@ const (
 	// Firebase server is closed.
 	EventTypeClosed EventType = "closed"
 
+	// EventTypeIdleTimeout is the event type sent when the connection with
+	// the Firebase server is forcibly closed after no events were received
+	// within the idle timeout.
+	EventTypeIdleTimeout EventType = "idle_timeout"
+
 	// EventTypeUnknownError is the event type sent when an unknown error is
 	// encountered.
 	EventTypeUnknownError EventType = "unknown_error"
@@ -58,10 +69,126 @@ func (e EventType) String() string {
 // Event is a Firebase server side event emitted from Watch and Listen.
 type Event struct {
 	Type EventType
-	Data []byte
+
+	// Path is the path of the data relative to the watched ref, and is only
+	// set for put and patch events.
+	Path string
+
+	// Data is the JSON-encoded data for put and patch events (ie, the "data"
+	// field of the event's payload), or the raw event data for all other
+	// events.
+	//
+	// NOTE: previously, Data held the complete payload of put and patch
+	// events (ie, {"path": ..., "data": ...}), which is now available as Raw.
+	Data json.RawMessage
+
+	// Raw is the unmodified data of the event as received from the server, or
+	// nil for events emitted by the package (ie, closed and error events).
+	Raw json.RawMessage
+
+	// dec is the decoder for the event's data.
+	dec decoder
+}
+
+// Decode decodes the event's data into d.
+func (e *Event) Decode(d interface{}) error {
+	return e.dec.decode(e.Data, d)
+}
+
+// NumberDecoding is how JSON numbers are decoded into interface{} values (see
+// DecodeNumbers).
+type NumberDecoding int
+
+const (
+	// AsNumber decodes numbers as json.Number, retaining their precision.
+	AsNumber NumberDecoding = iota
+
+	// AsFloat64 decodes numbers as float64, as with encoding/json.
+	AsFloat64
+)
+
+// decoder decodes JSON-encoded responses.
+type decoder struct {
+	numbers NumberDecoding
+	strict  bool
+
+	// codec decodes in place of encoding/json, when not nil (in which case
+	// numbers and strict are not applied).
+	codec Codec
+}
+
+// newDecoder creates a json.Decoder reading from rdr.
+func (dc decoder) newDecoder(rdr io.Reader) *json.Decoder {
+	dec := json.NewDecoder(rdr)
+	if dc.numbers == AsNumber {
+		dec.UseNumber()
+	}
+	if dc.strict {
+		dec.DisallowUnknownFields()
+	}
+	return dec
+}
+
+// decodeFrom decodes the JSON value read from rdr into d, returning io.EOF
+// when rdr is empty.
+func (dc decoder) decodeFrom(rdr io.Reader, d interface{}) error {
+	p, prioritized := d.(prioritizedDecoder)
+	if dc.codec == nil && !prioritized {
+		return dc.newDecoder(rdr).Decode(d)
+	}
+
+	buf, err := ioutil.ReadAll(rdr)
+	switch {
+	case err != nil:
+		return err
+	case len(bytes.TrimSpace(buf)) == 0:
+		return io.EOF
+	case prioritized:
+		return p.decodePrioritized(buf, dc)
+	}
+	return dc.codec.Unmarshal(buf, d)
+}
+
+// decode decodes buf into d.
+func (dc decoder) decode(buf []byte, d interface{}) error {
+	err := dc.decodeFrom(bytes.NewReader(buf), d)
+	if err != nil {
+		return &Error{
+			Err: fmt.Sprintf("could not unmarshal json: %v", err),
+			err: err,
+		}
+	}
+	return nil
+}
+
+// Err returns an error describing the event when the event is a terminal
+// event for a watch (ie, cancel, auth_revoked, or a synthesized error event),
+// and nil otherwise.
+func (e *Event) Err() error {
+	switch e.Type {
+	case EventTypePut, EventTypePatch, EventTypeKeepAlive:
+		return nil
+
+	case EventTypeCancel:
+		return &Error{
+			Err: "watch canceled by server",
+		}
+
+	case EventTypeAuthRevoked:
+		return &Error{
+			Err: fmt.Sprintf("watch auth revoked: %s", string(e.Data)),
+		}
+	}
+
+	return &Error{
+		Err: fmt.Sprintf("watch %s: %s", e.Type, string(e.Data)),
+	}
 }
 
 // String satisfies the stringer interface.
 func (e Event) String() string {
+	if e.Path != "" {
+		return fmt.Sprintf("%s %s: %s", e.Type, e.Path, string(e.Data))
+	}
 	return fmt.Sprintf("%s: %s", e.Type, string(e.Data))
 }
@@ -0,0 +1,206 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+const (
+	// DefaultExportThreshold is the default number of children above which
+	// Export retrieves a node's children in chunks.
+	DefaultExportThreshold = 1000
+)
+
+// exportConfig holds the configuration of an export.
+type exportConfig struct {
+	threshold int
+	chunkSize int
+	progress  func(bytes int64, children int)
+}
+
+// ExportOption is an option to modify an export.
+type ExportOption func(e *exportConfig) error
+
+// ExportThreshold is an export option that sets the number of children above
+// which the exported node's children are retrieved in chunks of chunkSize
+// children per request, instead of with a single request.
+func ExportThreshold(threshold, chunkSize int) ExportOption {
+	return func(e *exportConfig) error {
+		if threshold < 0 {
+			return fmt.Errorf("export threshold cannot be negative, got: %d", threshold)
+		}
+		if chunkSize < 1 {
+			return fmt.Errorf("export chunk size must be greater than 0, got: %d", chunkSize)
+		}
+
+		e.threshold, e.chunkSize = threshold, chunkSize
+		return nil
+	}
+}
+
+// ExportProgress is an export option that sets a func called with the total
+// number of bytes written and children exported, as the export progresses.
+func ExportProgress(f func(bytes int64, children int)) ExportOption {
+	return func(e *exportConfig) error {
+		e.progress = f
+		return nil
+	}
+}
+
+// Export streams the values stored at Firebase database ref r as JSON to w,
+// in the export format (see FormatExport), so that priorities are included,
+// without holding the values in memory.
+//
+// When r has more children than the export threshold (see ExportThreshold),
+// the children are listed with a shallow request, retrieved in chunks, and
+// written to w as a single JSON object. Otherwise, the response is copied to w
+// as it is read.
+//
+// When the export fails (or the context is done) mid-stream, the output
+// written to w is incomplete.
+func Export(r *DatabaseRef, w io.Writer, opts ...ExportOption) error {
+	return ExportContext(context.Background(), r, w, opts...)
+}
+
+// ExportContext streams the values stored at Firebase database ref r as JSON
+// to w, in the export format, using the provided context.
+func ExportContext(ctxt context.Context, r *DatabaseRef, w io.Writer, opts ...ExportOption) error {
+	var err error
+
+	// apply opts
+	e := &exportConfig{
+		threshold: DefaultExportThreshold,
+		chunkSize: DefaultExportThreshold,
+	}
+	for _, o := range opts {
+		err = o(e)
+		if err != nil {
+			return err
+		}
+	}
+
+	cw := &countingWriter{w: w}
+	var children int
+	progress := func() {
+		if e.progress != nil {
+			e.progress(cw.n, children)
+		}
+	}
+
+	// list keys
+	list, err := GetOrderedContext(ctxt, r, Shallow)
+	if err != nil && !errors.Is(err, ErrLeafNode) {
+		return err
+	}
+
+	// copy (small) node
+	if len(list) <= e.threshold {
+		rdr, err := GetReaderContext(ctxt, r, FormatExport)
+		if err != nil {
+			return err
+		}
+		defer rdr.Close()
+
+		if _, err = io.Copy(cw, rdr); err != nil {
+			return exportError(ctxt, err)
+		}
+		children = len(list)
+		progress()
+
+		return nil
+	}
+
+	keys := make([]string, len(list))
+	for i, kv := range list {
+		keys[i] = kv.Key
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		return keyLess(keys[i], keys[j])
+	})
+
+	// write children
+	if _, err = io.WriteString(cw, "{"); err != nil {
+		return exportError(ctxt, err)
+	}
+	err = getChunks(ctxt, r, keys, e.chunkSize, func(key string, raw json.RawMessage) error {
+		// the node's priority is written last
+		if key == ".priority" {
+			return nil
+		}
+
+		k, _ := json.Marshal(key)
+		if children != 0 {
+			k = append([]byte{','}, k...)
+		}
+		if _, err := cw.Write(append(append(k, ':'), raw...)); err != nil {
+			return exportError(ctxt, err)
+		}
+		children++
+		if children%e.chunkSize == 0 {
+			progress()
+		}
+		return nil
+	}, FormatExport)
+	if err != nil {
+		return err
+	}
+
+	// write priority
+	priority, err := GetRawContext(ctxt, r.Ref(".priority"))
+	if err != nil {
+		return err
+	}
+	if !isNull(priority) {
+		if _, err = cw.Write(append([]byte(`,".priority":`), priority...)); err != nil {
+			return exportError(ctxt, err)
+		}
+	}
+	if _, err = io.WriteString(cw, "}"); err != nil {
+		return exportError(ctxt, err)
+	}
+	progress()
+
+	return nil
+}
+
+// exportError returns the error for err encountered writing an export, which
+// is the context's error when the context is done.
+func exportError(ctxt context.Context, err error) error {
+	if ctxt.Err() != nil {
+		err = ctxt.Err()
+	}
+	return &Error{
+		Err: fmt.Sprintf("could not write export: %v", err),
+		err: err,
+	}
+}
+
+// countingWriter is an io.Writer counting the bytes written to the underlying
+// writer.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+// Write satisfies the io.Writer interface.
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// Export streams the values stored at the Firebase database ref as JSON to w,
+// in the export format.
+func (r *DatabaseRef) Export(w io.Writer, opts ...ExportOption) error {
+	return Export(r, w, opts...)
+}
+
+// ExportContext streams the values stored at the Firebase database ref as
+// JSON to w, in the export format, using the provided context.
+func (r *DatabaseRef) ExportContext(ctxt context.Context, w io.Writer, opts ...ExportOption) error {
+	return ExportContext(ctxt, r, w, opts...)
+}
@@ -0,0 +1,72 @@
+package firebase_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/knq/firebase"
+	"github.com/knq/firebase/firebasetest"
+)
+
+func TestExport(t *testing.T) {
+	srv := firebasetest.NewServer()
+	defer srv.Close()
+
+	data := map[string]interface{}{
+		"a": 1, "b": "two", "c": map[string]interface{}{"d": true}, "10": 10, "2": 2,
+	}
+	if err := srv.SetData("/users", data); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	r := srv.Ref().Ref("/users")
+
+	for i, opts := range [][]firebase.ExportOption{
+		nil,
+		{firebase.ExportThreshold(2, 2)},
+		{firebase.ExportThreshold(0, 1)},
+	} {
+		var progress []int
+		opts = append(opts, firebase.ExportProgress(func(n int64, children int) {
+			progress = append(progress, children)
+		}))
+
+		var buf bytes.Buffer
+		if err := r.Export(&buf, opts...); err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+
+		var v, exp interface{}
+		if err := json.Unmarshal(buf.Bytes(), &v); err != nil {
+			t.Fatalf("test %d expected valid json, got: %s (%v)", i, buf.String(), err)
+		}
+		json.Unmarshal(srv.Data("/users"), &exp)
+		if !reflect.DeepEqual(v, exp) {
+			t.Errorf("test %d expected %v, got: %v", i, exp, v)
+		}
+		if len(progress) == 0 || progress[len(progress)-1] != 5 {
+			t.Errorf("test %d expected progress to end with 5 children, got: %v", i, progress)
+		}
+	}
+
+	// leaf and missing nodes
+	for path, exp := range map[string]string{"/users/a": "1", "/missing": "null"} {
+		var buf bytes.Buffer
+		if err := srv.Ref().Ref(path).Export(&buf); err != nil || buf.String() != exp {
+			t.Errorf("path %s expected %s, got: %s (%v)", path, exp, buf.String(), err)
+		}
+	}
+
+	// canceled mid-stream
+	ctxt, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := r.ExportContext(ctxt, new(bytes.Buffer), firebase.ExportThreshold(1, 1), firebase.ExportProgress(func(int64, int) {
+		cancel()
+	}))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got: %v", err)
+	}
+}
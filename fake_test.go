@@ -0,0 +1,645 @@
+package firebase_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/knq/firebase"
+	"github.com/knq/firebase/firebasetest"
+)
+
+func TestSetIfUnchanged(t *testing.T) {
+	srv := firebasetest.NewServer()
+	defer srv.Close()
+
+	if err := srv.SetData("/v", "foo"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	r := srv.Ref().Ref("/v")
+	etag, err := r.GetWithETag(nil)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// mismatch
+	newEtag, err := r.SetIfUnchanged("bad", "bar")
+	if !errors.Is(err, firebase.ErrETagMismatch) {
+		t.Fatalf("expected ErrETagMismatch, got: %v", err)
+	}
+	if newEtag != etag {
+		t.Errorf("expected %q, got: %q", etag, newEtag)
+	}
+	var e *firebase.ETagMismatchError
+	if !errors.As(err, &e) {
+		t.Fatalf("expected *ETagMismatchError, got: %T", err)
+	}
+	if e.ETag != etag || string(e.Value) != `"foo"` {
+		t.Errorf("expected %q and \"foo\", got: %q and %s", etag, e.ETag, string(e.Value))
+	}
+
+	// match
+	newEtag, err = r.SetIfUnchanged(e.ETag, "bar")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if newEtag == etag || newEtag == "" {
+		t.Errorf("expected new etag, got: %q", newEtag)
+	}
+	if s := string(srv.Data("/v")); s != `"bar"` {
+		t.Errorf("expected \"bar\" to be stored, got: %s", s)
+	}
+}
+
+func TestRemoveIfUnchanged(t *testing.T) {
+	srv := firebasetest.NewServer()
+	defer srv.Close()
+
+	if err := srv.SetData("/v", "foo"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	r := srv.Ref().Ref("/v")
+	etag, err := r.GetWithETag(nil)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// mismatch
+	err = r.RemoveIfUnchanged("bad")
+	var e *firebase.ETagMismatchError
+	if !errors.As(err, &e) || !errors.Is(err, firebase.ErrETagMismatch) {
+		t.Fatalf("expected *ETagMismatchError, got: %v", err)
+	}
+	if e.ETag != etag {
+		t.Errorf("expected %q, got: %q", etag, e.ETag)
+	}
+
+	// match
+	if err = r.RemoveIfUnchanged(e.ETag); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s := string(srv.Data("/v")); s != "null" {
+		t.Errorf("expected null, got: %s", s)
+	}
+
+	// already removed
+	if err = r.RemoveIfUnchanged(etag); err != nil {
+		t.Errorf("expected no error removing already removed node, got: %v", err)
+	}
+}
+
+func TestGetIfChanged(t *testing.T) {
+	srv := firebasetest.NewServer()
+	defer srv.Close()
+
+	if err := srv.SetData("/v", "foo"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	r := srv.Ref().Ref("/v")
+	etag, err := r.GetWithETag(nil)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// not modified
+	v := "untouched"
+	changed, newEtag, err := r.GetIfChanged(etag, &v)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if changed || newEtag != etag || v != "untouched" {
+		t.Errorf("expected unchanged, got: %t %q %q", changed, newEtag, v)
+	}
+
+	// modified
+	if err := srv.SetData("/v", "bar"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	changed, newEtag, err = r.GetIfChanged(etag, &v)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !changed || newEtag == etag || v != "bar" {
+		t.Errorf("expected changed, got: %t %q %q", changed, newEtag, v)
+	}
+}
+
+// newFakeRef creates a database ref for the fake server srv, recording the
+// requests made with the returned doer, and denying requests for paths ending
+// with /denied.
+func newFakeRef(srv *firebasetest.Server) (*firebase.DatabaseRef, *firebasetest.RecordingDoer) {
+	base := srv.Client().Transport
+	d := firebasetest.NewRecordingDoer(&http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if strings.HasSuffix(req.URL.Path, "/denied.json") {
+				return &http.Response{
+					StatusCode: http.StatusUnauthorized,
+					Header:     http.Header{"Content-Type": {"application/json"}},
+					Body:       ioutil.NopCloser(strings.NewReader(`{"error":"Permission denied"}`)),
+					Request:    req,
+				}, nil
+			}
+			return base.RoundTrip(req)
+		}),
+	})
+	return srv.Ref(firebase.HTTPDoer(d)), d
+}
+
+func TestReadWrite(t *testing.T) {
+	srv := firebasetest.NewServer()
+	defer srv.Close()
+
+	r, _ := newFakeRef(srv)
+
+	type person struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+
+	if err := r.Ref("/people/a").Set(person{"amy", 30}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := r.Ref("/people").Update(map[string]interface{}{"a/age": 31, "b": person{"bob", 40}}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	var m map[string]person
+	if err := r.Ref("/people").Get(&m); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if exp := map[string]person{"a": {"amy", 31}, "b": {"bob", 40}}; !reflect.DeepEqual(m, exp) {
+		t.Errorf("expected %v, got: %v", exp, m)
+	}
+
+	id, err := r.Ref("/people").Push(person{"cat", 50})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	var p person
+	if err := r.Ref("/people/" + id).Get(&p); err != nil || p != (person{"cat", 50}) {
+		t.Errorf("expected pushed value, got: %v (%v)", p, err)
+	}
+
+	if err := r.Ref("/people/a").Remove(); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	keys, err := r.Ref("/people").GetShallowKeys()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if exp := []string{id, "b"}; !reflect.DeepEqual(keys, exp) {
+		t.Errorf("expected %v, got: %v", exp, keys)
+	}
+}
+
+func TestGetShallowKeys(t *testing.T) {
+	srv := firebasetest.NewServer()
+	defer srv.Close()
+
+	if err := srv.SetData("/", map[string]interface{}{
+		"obj": map[string]interface{}{"b": 1, "c": map[string]interface{}{"d": 2}, "a": "x"},
+		"str": "leaf",
+		"num": 15,
+	}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	r, d := newFakeRef(srv)
+
+	tests := []struct {
+		path string
+		exp  []string
+		err  error
+	}{
+		{"/obj", []string{"a", "b", "c"}, nil},
+		{"/missing", []string{}, nil},
+		{"/str", nil, firebase.ErrLeafNode},
+		{"/num", nil, firebase.ErrLeafNode},
+	}
+	for i, test := range tests {
+		d.Reset()
+		keys, err := r.Ref(test.path).GetShallowKeys()
+		if err != test.err {
+			t.Errorf("test %d expected error %v, got: %v", i, test.err, err)
+			continue
+		}
+		if reqs := d.Requests(); len(reqs) != 1 || reqs[0].URL.Query().Get("shallow") != "true" {
+			t.Errorf("test %d expected a shallow request, got: %v", i, reqs)
+		}
+		if test.err != nil {
+			continue
+		}
+		if !reflect.DeepEqual(keys, test.exp) {
+			t.Errorf("test %d expected %v, got: %v", i, test.exp, keys)
+		}
+	}
+}
+
+func TestPrintSilent(t *testing.T) {
+	srv := firebasetest.NewServer()
+	defer srv.Close()
+
+	r, d := newFakeRef(srv)
+
+	if err := r.Set(map[string]interface{}{"a": 1}, firebase.PrintSilent); err != nil {
+		t.Errorf("expected no error on set, got: %v", err)
+	}
+	if err := r.Update(map[string]interface{}{"b": 2}, firebase.PrintSilent); err != nil {
+		t.Errorf("expected no error on update, got: %v", err)
+	}
+	var v interface{}
+	if err := firebase.Do(firebase.OpTypeSet, r.Ref("/c"), 1, &v, firebase.PrintSilent); err != nil {
+		t.Errorf("expected no error decoding empty body, got: %v", err)
+	}
+	for i, req := range d.Requests() {
+		if p := req.URL.Query().Get("print"); p != "silent" {
+			t.Errorf("request %d expected print=silent, got: %q", i, p)
+		}
+	}
+	if s, exp := string(srv.Data("/")), `{"a":1,"b":2,"c":1}`; s != exp {
+		t.Errorf("expected %s, got: %s", exp, s)
+	}
+
+	if _, err := r.Push(1, firebase.PrintSilent); err == nil || !strings.Contains(err.Error(), "PrintSilent") {
+		t.Errorf("expected push error mentioning PrintSilent, got: %v", err)
+	}
+}
+
+func TestPushRef(t *testing.T) {
+	srv := firebasetest.NewServer()
+	defer srv.Close()
+
+	r, _ := newFakeRef(srv)
+	for _, path := range []string{"/people", "/people/"} {
+		child, err := r.Ref(path).PushRef(map[string]string{"name": "john"})
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		p := child.URL().Path
+		if !strings.HasPrefix(p, "/people/") || strings.Count(p, "/") != 2 {
+			t.Errorf("expected /people/<id>, got: %s", p)
+		}
+		if s := string(srv.Data(p)); s != `{"name":"john"}` {
+			t.Errorf("expected pushed value at %s, got: %s", p, s)
+		}
+	}
+}
+
+func TestGetOrdered(t *testing.T) {
+	srv := firebasetest.NewServer()
+	defer srv.Close()
+
+	if err := srv.SetData("/", map[string]interface{}{
+		"scores": map[string]interface{}{
+			"zed": map[string]interface{}{"score": 1},
+			"amy": map[string]interface{}{"score": 5},
+			"mia": map[string]interface{}{"score": 9},
+			"bob": map[string]interface{}{"score": 0},
+		},
+		"list": []interface{}{"a", nil, "c"},
+		"leaf": 10,
+	}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	r, _ := newFakeRef(srv)
+
+	query := []firebase.QueryOption{firebase.OrderBy("score"), firebase.LimitToLast(3)}
+	tests := []struct {
+		path string
+		opts []firebase.QueryOption
+		exp  []firebase.KeyedValue
+		err  error
+	}{
+		{"/scores", query, []firebase.KeyedValue{
+			{Key: "zed", Value: json.RawMessage(`{"score":1}`)},
+			{Key: "amy", Value: json.RawMessage(`{"score":5}`)},
+			{Key: "mia", Value: json.RawMessage(`{"score":9}`)},
+		}, nil},
+		{"/list", query, []firebase.KeyedValue{
+			{Key: "0", Value: json.RawMessage(`"a"`)},
+			{Key: "2", Value: json.RawMessage(`"c"`)},
+		}, nil},
+		{"/list", nil, []firebase.KeyedValue{
+			{Key: "0", Value: json.RawMessage(`"a"`)},
+			{Key: "2", Value: json.RawMessage(`"c"`)},
+		}, nil},
+		{"/empty", query, []firebase.KeyedValue{}, nil},
+		{"/leaf", nil, nil, firebase.ErrLeafNode},
+	}
+	for i, test := range tests {
+		kvs, err := r.Ref(test.path).GetOrdered(test.opts...)
+		if err != test.err {
+			t.Fatalf("test %d expected error %v, got: %v", i, test.err, err)
+		}
+		if !reflect.DeepEqual(kvs, test.exp) {
+			t.Errorf("test %d expected %v, got: %v", i, test.exp, kvs)
+		}
+	}
+}
+
+func TestGetChildren(t *testing.T) {
+	srv := firebasetest.NewServer()
+	defer srv.Close()
+
+	if err := srv.SetData("/", map[string]interface{}{
+		"mixed": map[string]interface{}{
+			"a": map[string]interface{}{"name": "amy"},
+			"b": []interface{}{1, 2},
+			"c": "str",
+		},
+		"leaf": "str",
+	}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	r, _ := newFakeRef(srv)
+
+	m, err := r.Ref("/mixed").GetChildren()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	exp := map[string]json.RawMessage{
+		"a": json.RawMessage(`{"name":"amy"}`),
+		"b": json.RawMessage(`[1,2]`),
+		"c": json.RawMessage(`"str"`),
+	}
+	if !reflect.DeepEqual(m, exp) {
+		t.Errorf("expected %v, got: %v", exp, m)
+	}
+
+	m, err = r.Ref("/missing").GetChildren()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if m == nil || len(m) != 0 {
+		t.Errorf("expected empty map, got: %v", m)
+	}
+
+	if _, err = r.Ref("/leaf").GetChildren(); err != firebase.ErrLeafNode {
+		t.Errorf("expected ErrLeafNode, got: %v", err)
+	}
+}
+
+func TestExists(t *testing.T) {
+	srv := firebasetest.NewServer()
+	defer srv.Close()
+
+	if err := srv.SetData("/", map[string]interface{}{
+		"node": map[string]interface{}{"a": 1, "b": 2},
+		"leaf": 0,
+	}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	r, d := newFakeRef(srv)
+
+	tests := []struct {
+		path    string
+		exp     bool
+		queries []string
+	}{
+		{"/node", true, []string{"/node.json?shallow=true"}},
+		{"/leaf", true, []string{"/leaf.json?shallow=true"}},
+		{"/missing", false, []string{"/missing.json?shallow=true"}},
+	}
+	for i, test := range tests {
+		d.Reset()
+		ok, err := r.Ref(test.path).Exists()
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		if ok != test.exp {
+			t.Errorf("test %d expected %t, got: %t", i, test.exp, ok)
+		}
+		var queries []string
+		for _, req := range d.Requests() {
+			queries = append(queries, req.URL.Path+"?"+req.URL.Query().Encode())
+		}
+		if !reflect.DeepEqual(queries, test.queries) {
+			t.Errorf("test %d expected queries %v, got: %v", i, test.queries, queries)
+		}
+	}
+
+	if _, err := r.Ref("/denied").Exists(); !errors.Is(err, firebase.ErrPermissionDenied) {
+		t.Errorf("expected ErrPermissionDenied, got: %v", err)
+	}
+}
+
+func TestCountChildren(t *testing.T) {
+	srv := firebasetest.NewServer()
+	defer srv.Close()
+
+	many := make(map[string]interface{}, 10000)
+	for i := 0; i < 10000; i++ {
+		many[fmt.Sprintf("key%d", i)] = map[string]interface{}{"n": i}
+	}
+	if err := srv.SetData("/", map[string]interface{}{
+		"many": many,
+		"list": []interface{}{true, nil, true},
+		"leaf": "str",
+	}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	r, d := newFakeRef(srv)
+
+	tests := []struct {
+		path string
+		exp  int
+		err  error
+	}{
+		{"/many", 10000, nil},
+		{"/list", 2, nil},
+		{"/missing", 0, nil},
+		{"/leaf", 0, firebase.ErrLeafNode},
+		{"/denied", 0, firebase.ErrPermissionDenied},
+	}
+	for i, test := range tests {
+		d.Reset()
+		n, err := r.Ref(test.path).CountChildren()
+		if !errors.Is(err, test.err) || (test.err == nil && err != nil) {
+			t.Errorf("test %d expected error %v, got: %v", i, test.err, err)
+		}
+		if n != test.exp {
+			t.Errorf("test %d expected %d, got: %d", i, test.exp, n)
+		}
+		if reqs := d.Requests(); len(reqs) != 1 || reqs[0].URL.Query().Get("shallow") != "true" {
+			t.Errorf("test %d expected a shallow request, got: %v", i, reqs)
+		}
+	}
+}
+
+func TestChildren(t *testing.T) {
+	srv := firebasetest.NewServer()
+	defer srv.Close()
+
+	data := map[string]int{"a": 1, "b": 2, "c": 3, "d": 4, "e": 5}
+	if err := srv.SetData("/items", data); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	r, d := newFakeRef(srv)
+
+	it := r.Ref("/items").Children(2)
+	if n := len(d.Requests()); n != 0 {
+		t.Fatalf("expected no requests before Next, got: %d", n)
+	}
+
+	var keys []string
+	for it.Next() {
+		var v int
+		if err := it.Decode(&v); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if v != data[it.Key()] {
+			t.Errorf("expected %s to be %d, got: %d", it.Key(), data[it.Key()], v)
+		}
+		keys = append(keys, it.Key())
+
+		// add children before and after the cursor
+		if it.Key() == "b" {
+			data["aa"], data["f"] = 0, 6
+			if err := srv.SetData("/items", data); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+		}
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	exp := []string{"a", "b", "c", "d", "e", "f"}
+	if !reflect.DeepEqual(keys, exp) {
+		t.Errorf("expected %v, got: %v", exp, keys)
+	}
+	reqs := d.Requests()
+	if len(reqs) != 4 {
+		t.Errorf("expected 4 requests, got: %d", len(reqs))
+	}
+	for i, req := range reqs {
+		if ob := req.URL.Query().Get("orderBy"); ob != `"$key"` {
+			t.Errorf("request %d expected orderBy \"$key\", got: %s", i, ob)
+		}
+	}
+}
+
+func TestGetPage(t *testing.T) {
+	srv := firebasetest.NewServer()
+	defer srv.Close()
+
+	if err := srv.SetData("/items", map[string]interface{}{
+		"e": map[string]int{"age": 20},
+		"a": map[string]int{"age": 40},
+		"d": map[string]int{"age": 30},
+		"b": map[string]int{"age": 20},
+		"c": map[string]int{"age": 10},
+	}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	r, _ := newFakeRef(srv)
+	r = r.Ref("/items")
+
+	tests := []struct {
+		opts []firebase.QueryOption
+		exp  []string
+	}{
+		{nil, []string{"a", "b", "c", "d", "e"}},
+		{[]firebase.QueryOption{firebase.OrderBy("age")}, []string{"c", "b", "e", "d", "a"}},
+	}
+	for i, test := range tests {
+		var keys []string
+		var cursor string
+		var pages int
+		for {
+			items, next, err := r.GetPage(2, cursor, test.opts...)
+			if err != nil {
+				t.Fatalf("test %d expected no error, got: %v", i, err)
+			}
+			if len(items) > 2 {
+				t.Fatalf("test %d expected at most 2 items, got: %d", i, len(items))
+			}
+			for _, kv := range items {
+				keys = append(keys, kv.Key)
+			}
+			pages++
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+		if !reflect.DeepEqual(keys, test.exp) {
+			t.Errorf("test %d expected %v, got: %v", i, test.exp, keys)
+		}
+		if pages != 3 {
+			t.Errorf("test %d expected 3 pages, got: %d", i, pages)
+		}
+	}
+}
+
+func TestGetChunked(t *testing.T) {
+	srv := firebasetest.NewServer()
+	defer srv.Close()
+
+	if err := srv.SetData("/items", map[string]int{"a": 1, "b": 2, "c": 3, "d": 4, "e": 5}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	r, d := newFakeRef(srv)
+	r = r.Ref("/items")
+
+	// windows returns the key ranges of the chunk requests
+	windows := func() []string {
+		var windows []string
+		for _, req := range d.Requests() {
+			q := req.URL.Query()
+			if q.Get("shallow") == "true" {
+				continue
+			}
+			var start, end string
+			json.Unmarshal([]byte(q.Get("startAt")), &start)
+			json.Unmarshal([]byte(q.Get("endAt")), &end)
+			windows = append(windows, start+"-"+end)
+		}
+		return windows
+	}
+
+	var keys []string
+	err := r.GetChunked(2, func(key string, raw json.RawMessage) error {
+		keys = append(keys, key+"="+string(raw))
+		// remove a child after the keys were listed
+		if key == "a" {
+			if err := srv.SetData("/items/c", nil); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if exp := []string{"a=1", "b=2", "d=4", "e=5"}; !reflect.DeepEqual(keys, exp) {
+		t.Errorf("expected %v, got: %v", exp, keys)
+	}
+	if exp, w := []string{"a-b", "c-d", "e-e"}, windows(); !reflect.DeepEqual(w, exp) {
+		t.Errorf("expected windows %v, got: %v", exp, w)
+	}
+
+	// resume and stop early
+	keys = nil
+	d.Reset()
+	stop := errors.New("stop")
+	err = firebase.GetChunkedFrom(r, "b", 1, func(key string, raw json.RawMessage) error {
+		keys = append(keys, key)
+		if key == "d" {
+			return stop
+		}
+		return nil
+	})
+	if err != stop {
+		t.Errorf("expected stop error, got: %v", err)
+	}
+	if exp := []string{"b", "d"}; !reflect.DeepEqual(keys, exp) {
+		t.Errorf("expected %v, got: %v", exp, keys)
+	}
+	if exp, w := []string{"b-b", "d-d"}, windows(); !reflect.DeepEqual(w, exp) {
+		t.Errorf("expected windows %v, got: %v", exp, w)
+	}
+}
@@ -0,0 +1,106 @@
+package firebasetest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/knq/firebase"
+)
+
+// Request is a request recorded by a RecordingDoer.
+type Request struct {
+	Method string
+	URL    *url.URL
+	Header http.Header
+
+	// Body is the request body, decompressed when the request was sent with
+	// Content-Encoding: gzip.
+	Body []byte
+}
+
+// RecordingDoer is a firebase.Doer that records the requests it is passed,
+// for asserting on the exact requests made by the firebase package (see the
+// firebase.HTTPDoer option).
+//
+// Requests are passed to Next, or when Next is nil, are responded to with a
+// 200 OK response with a JSON null body.
+type RecordingDoer struct {
+	Next firebase.Doer
+
+	mu       sync.Mutex
+	requests []Request
+}
+
+// NewRecordingDoer creates a recording doer passing requests to next, which
+// may be nil.
+func NewRecordingDoer(next firebase.Doer) *RecordingDoer {
+	return &RecordingDoer{Next: next}
+}
+
+// Do satisfies the firebase.Doer interface.
+func (d *RecordingDoer) Do(req *http.Request) (*http.Response, error) {
+	rec := Request{
+		Method: req.Method,
+		URL:    req.URL,
+		Header: req.Header.Clone(),
+	}
+
+	// read body
+	if req.Body != nil {
+		buf, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(buf))
+
+		rec.Body = buf
+		if req.Header.Get("Content-Encoding") == "gzip" {
+			if zr, err := gzip.NewReader(bytes.NewReader(buf)); err == nil {
+				if b, err := ioutil.ReadAll(zr); err == nil {
+					rec.Body = b
+				}
+			}
+		}
+	}
+
+	d.mu.Lock()
+	d.requests = append(d.requests, rec)
+	d.mu.Unlock()
+
+	if d.Next != nil {
+		return d.Next.Do(req)
+	}
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json; charset=utf-8"}},
+		Body:          ioutil.NopCloser(bytes.NewReader([]byte("null"))),
+		ContentLength: 4,
+		Request:       req,
+	}, nil
+}
+
+// Requests returns the recorded requests.
+func (d *RecordingDoer) Requests() []Request {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]Request(nil), d.requests...)
+}
+
+// Reset clears the recorded requests.
+func (d *RecordingDoer) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.requests = nil
+}
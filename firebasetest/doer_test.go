@@ -0,0 +1,52 @@
+package firebasetest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/knq/firebase"
+)
+
+func TestRecordingDoer(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	d := NewRecordingDoer(srv.Client())
+	r := srv.Ref(firebase.HTTPDoer(d))
+
+	if err := r.Ref("/a").Set("foo", firebase.PrintSilent); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s := string(srv.Data("/a")); s != `"foo"` {
+		t.Errorf("expected \"foo\" to be stored, got: %s", s)
+	}
+
+	reqs := d.Requests()
+	if len(reqs) != 1 {
+		t.Fatalf("expected 1 request, got: %d", len(reqs))
+	}
+	req := reqs[0]
+	if req.Method != "PUT" || req.URL.Path != "/a.json" || req.URL.Query().Get("print") != "silent" {
+		t.Errorf("expected PUT /a.json?print=silent, got: %s %s", req.Method, req.URL)
+	}
+	if string(req.Body) != `"foo"` {
+		t.Errorf("expected body \"foo\", got: %s", string(req.Body))
+	}
+
+	// no next doer
+	d = NewRecordingDoer(nil)
+	var v interface{}
+	if err := srv.Ref(firebase.HTTPDoer(d)).Get(&v, firebase.Header("X-Test", "1")); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if v != nil {
+		t.Errorf("expected nil, got: %v", v)
+	}
+	if reqs := d.Requests(); len(reqs) != 1 || reqs[0].Method != http.MethodGet || reqs[0].Header.Get("X-Test") != "1" {
+		t.Errorf("expected GET with X-Test header, got: %v", reqs)
+	}
+	d.Reset()
+	if n := len(d.Requests()); n != 0 {
+		t.Errorf("expected no requests after reset, got: %d", n)
+	}
+}
@@ -0,0 +1,692 @@
+// Package firebasetest provides an in-memory fake of the Firebase Realtime
+// Database REST API, for testing code using the firebase package.
+//
+// The fake stores a JSON tree in memory, and implements the GET, PUT, POST,
+// PATCH, and DELETE methods (with the .json suffix), shallow reads, ordered
+// and filtered queries (orderBy, startAt, startAfter, endAt, endBefore,
+// equalTo, limitToFirst, and limitToLast), push ID generation, server values
+// (timestamps and increments), and ETags (X-Firebase-ETag, If-Match, and
+// If-None-Match). Streaming (Watch) and security rules are not implemented.
+//
+// Example:
+//
+//	srv := firebasetest.NewServer()
+//	defer srv.Close()
+//
+//	srv.SetData("/users/a", map[string]interface{}{"name": "amy"})
+//
+//	var name string
+//	err := srv.Ref().Ref("/users/a/name").Get(&name)
+package firebasetest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/knq/firebase"
+)
+
+// Server is an in-memory fake Firebase database server.
+type Server struct {
+	*httptest.Server
+
+	mu   sync.Mutex
+	root interface{}
+}
+
+// NewServer creates and starts a new, empty fake Firebase database server.
+// The server should be closed when finished.
+func NewServer() *Server {
+	s := new(Server)
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// Ref creates a Firebase database ref for the root of the server, with the
+// provided options.
+//
+// Ref panics when any of the options return an error.
+func (s *Server) Ref(opts ...firebase.Option) *firebase.DatabaseRef {
+	r, err := firebase.NewDatabaseRef(append([]firebase.Option{firebase.URL(s.URL + "/")}, opts...)...)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// SetData stores v (encoded as JSON) at path, replacing the stored value, for
+// seeding fixtures. A nil v removes the value stored at path.
+func (s *Server) SetData(path string, v interface{}) error {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	val, err := decode(bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	segs := split(path)
+	s.set(segs, normalize(resolve(val, s.get(segs))))
+
+	return nil
+}
+
+// Data returns the JSON-encoded value stored at path.
+func (s *Server) Data(path string) json.RawMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	buf, _ := json.Marshal(render(s.get(split(path))))
+	return buf
+}
+
+// ServeHTTP satisfies the http.Handler interface.
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !strings.HasSuffix(req.URL.Path, ".json") {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	segs := split(strings.TrimSuffix(req.URL.Path, ".json"))
+	q := req.URL.Query()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur := s.get(segs)
+	etag := etagOf(cur)
+	wantETag := req.Header.Get("X-Firebase-ETag") == "true"
+
+	// read
+	if req.Method == "GET" {
+		if wantETag {
+			w.Header().Set("ETag", etag)
+		}
+		if m := req.Header.Get("If-None-Match"); m != "" && m == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		v, err := query(cur, q)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, v)
+		return
+	}
+
+	// conditional write
+	if m := req.Header.Get("If-Match"); m != "" && m != etag {
+		w.Header().Set("ETag", etag)
+		writeJSON(w, http.StatusPreconditionFailed, cur)
+		return
+	}
+
+	// decode body
+	var body interface{}
+	if req.Method != "DELETE" {
+		var rd io.Reader = req.Body
+		if req.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(req.Body)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid gzip body")
+				return
+			}
+			rd = zr
+		}
+		var err error
+		if body, err = decode(rd); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid data; couldn't parse JSON object, array, or value.")
+			return
+		}
+	}
+
+	// write
+	var res interface{}
+	switch req.Method {
+	case "PUT":
+		s.set(segs, normalize(resolve(body, cur)))
+		res = s.get(segs)
+
+	case "POST":
+		name := firebase.GeneratePushID()
+		s.set(append(segs, name), normalize(resolve(body, nil)))
+		res = map[string]interface{}{"name": name}
+
+	case "PATCH":
+		m, ok := body.(map[string]interface{})
+		if !ok {
+			writeError(w, http.StatusBadRequest, "Invalid data; couldn't parse JSON object.")
+			return
+		}
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			p := append(segs[:len(segs):len(segs)], split(k)...)
+			s.set(p, normalize(resolve(m[k], s.get(p))))
+		}
+		res = m
+
+	case "DELETE":
+		s.set(segs, nil)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if wantETag {
+		w.Header().Set("ETag", etagOf(s.get(segs)))
+	}
+	if q.Get("print") == "silent" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// get returns the value stored at segs.
+func (s *Server) get(segs []string) interface{} {
+	v := s.root
+	for _, seg := range segs {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[seg]
+	}
+	return v
+}
+
+// set stores v at segs, removing the value when v is nil, and pruning empty
+// parents.
+func (s *Server) set(segs []string, v interface{}) {
+	s.root = setIn(s.root, segs, v)
+}
+
+// setIn returns node with v stored at segs.
+func setIn(node interface{}, segs []string, v interface{}) interface{} {
+	if len(segs) == 0 {
+		return v
+	}
+
+	m, ok := node.(map[string]interface{})
+	if !ok {
+		if v == nil {
+			return node
+		}
+		m = make(map[string]interface{})
+	}
+
+	c := setIn(m[segs[0]], segs[1:], v)
+	if c == nil {
+		delete(m, segs[0])
+	} else {
+		m[segs[0]] = c
+	}
+	if len(m) == 0 {
+		return nil
+	}
+
+	return m
+}
+
+// split splits path into its segments.
+func split(path string) []string {
+	var segs []string
+	for _, seg := range strings.Split(path, "/") {
+		if seg != "" {
+			segs = append(segs, seg)
+		}
+	}
+	return segs
+}
+
+// decode decodes the JSON value read from r.
+func decode(r io.Reader) (interface{}, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// normalize converts v to the stored representation, converting arrays to
+// objects and removing null values and empty objects.
+func normalize(v interface{}) interface{} {
+	switch x := v.(type) {
+	case []interface{}:
+		m := make(map[string]interface{}, len(x))
+		for i, c := range x {
+			m[strconv.Itoa(i)] = c
+		}
+		return normalize(m)
+
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, c := range x {
+			if c = normalize(c); c != nil {
+				m[k] = c
+			}
+		}
+		if len(m) == 0 {
+			return nil
+		}
+		return m
+	}
+	return v
+}
+
+// resolve replaces the server values (ie, timestamps and increments) in v,
+// with cur being the currently stored value.
+func resolve(v, cur interface{}) interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+
+	if sv, ok := m[".sv"]; ok && len(m) == 1 {
+		switch x := sv.(type) {
+		case string:
+			if x == "timestamp" {
+				return json.Number(strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10))
+			}
+		case map[string]interface{}:
+			if n, ok := x["increment"].(json.Number); ok {
+				return increment(cur, n)
+			}
+		}
+		return v
+	}
+
+	cm, _ := cur.(map[string]interface{})
+	for k, c := range m {
+		m[k] = resolve(c, cm[k])
+	}
+
+	return m
+}
+
+// increment returns cur incremented by n, or n when cur is not a number.
+func increment(cur interface{}, n json.Number) json.Number {
+	c, ok := cur.(json.Number)
+	if !ok {
+		return n
+	}
+
+	a, aerr := c.Int64()
+	b, berr := n.Int64()
+	if aerr == nil && berr == nil {
+		return json.Number(strconv.FormatInt(a+b, 10))
+	}
+
+	x, _ := c.Float64()
+	y, _ := n.Float64()
+	return json.Number(strconv.FormatFloat(x+y, 'g', -1, 64))
+}
+
+// render converts the stored value v to its returned representation,
+// converting objects with mostly sequential integer keys to arrays, as
+// Firebase does.
+func render(v interface{}) interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+
+	max := -1
+	for k := range m {
+		i, err := strconv.Atoi(k)
+		if err != nil || i < 0 || strconv.Itoa(i) != k {
+			max = -1
+			break
+		}
+		if i > max {
+			max = i
+		}
+	}
+	if max >= 0 && len(m)*2 > max+1 {
+		a := make([]interface{}, max+1)
+		for k, c := range m {
+			i, _ := strconv.Atoi(k)
+			a[i] = render(c)
+		}
+		return a
+	}
+
+	r := make(map[string]interface{}, len(m))
+	for k, c := range m {
+		r[k] = render(c)
+	}
+	return r
+}
+
+// etagOf returns the ETag of v.
+func etagOf(v interface{}) string {
+	if v == nil {
+		return firebase.NullETag
+	}
+	buf, _ := json.Marshal(v)
+	sum := sha1.Sum(buf)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// writeJSON writes v as the JSON response with status.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	buf, _ := json.Marshal(render(v))
+	w.Write(buf)
+}
+
+// writeError writes the error response msg with status.
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]interface{}{"error": msg})
+}
+
+// filters are the query parameters that require orderBy.
+var filters = []string{
+	"startAt", "startAfter", "endAt", "endBefore", "equalTo",
+	"limitToFirst", "limitToLast",
+}
+
+// child is a child of a queried node.
+type child struct {
+	key   string
+	val   interface{}
+	order interface{}
+}
+
+// query applies the query parameters q to the value v.
+func query(v interface{}, q url.Values) (interface{}, error) {
+	ob := q.Get("orderBy")
+	if q.Get("shallow") == "true" {
+		for _, f := range append([]string{"orderBy"}, filters...) {
+			if q.Get(f) != "" {
+				return nil, fmt.Errorf("shallow cannot be combined with other query parameters")
+			}
+		}
+	}
+	if ob == "" {
+		for _, f := range filters {
+			if q.Get(f) != "" {
+				return nil, fmt.Errorf("orderBy must be defined when other query parameters are defined")
+			}
+		}
+		return shallow(v, q), nil
+	}
+
+	var orderBy string
+	if err := json.Unmarshal([]byte(ob), &orderBy); err != nil {
+		return nil, fmt.Errorf("orderBy must be a valid JSON encoded path")
+	}
+	if orderBy == "$priority" {
+		return nil, fmt.Errorf("orderBy $priority is not supported")
+	}
+
+	// firebase returns null for queries on leaf nodes
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	// order children
+	children := make([]child, 0, len(m))
+	for k, c := range m {
+		children = append(children, child{key: k, val: c, order: orderValue(orderBy, k, c)})
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return compareChildren(orderBy, children[i], children[j].order, &children[j].key) < 0
+	})
+
+	// filter
+	for _, f := range []struct {
+		param string
+		keep  func(int) bool
+	}{
+		{"startAt", func(c int) bool { return c >= 0 }},
+		{"startAfter", func(c int) bool { return c > 0 }},
+		{"endAt", func(c int) bool { return c <= 0 }},
+		{"endBefore", func(c int) bool { return c < 0 }},
+		{"equalTo", func(c int) bool { return c == 0 }},
+	} {
+		s := q.Get(f.param)
+		if s == "" {
+			continue
+		}
+		val, key, err := parseCursor(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a valid JSON value", f.param)
+		}
+		var kept []child
+		for _, c := range children {
+			if f.keep(compareChildren(orderBy, c, val, key)) {
+				kept = append(kept, c)
+			}
+		}
+		children = kept
+	}
+
+	// limit
+	if s := q.Get("limitToFirst"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("limitToFirst must be a positive integer")
+		}
+		if n < len(children) {
+			children = children[:n]
+		}
+	}
+	if s := q.Get("limitToLast"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("limitToLast must be a positive integer")
+		}
+		if n < len(children) {
+			children = children[len(children)-n:]
+		}
+	}
+
+	return ordered(children), nil
+}
+
+// ordered is the ordered result of a query, encoded as a JSON object with the
+// children in order.
+type ordered []child
+
+// MarshalJSON satisfies the json.Marshaler interface.
+func (o ordered) MarshalJSON() ([]byte, error) {
+	buf := []byte{'{'}
+	for i, c := range o {
+		if i != 0 {
+			buf = append(buf, ',')
+		}
+		k, err := json.Marshal(c.key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(render(c.val))
+		if err != nil {
+			return nil, err
+		}
+		buf = append(append(append(buf, k...), ':'), v...)
+	}
+	return append(buf, '}'), nil
+}
+
+// shallow returns the shallow representation of v (ie, with children that
+// have children replaced by true, and primitive children retained) when
+// requested by q.
+func shallow(v interface{}, q url.Values) interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok || q.Get("shallow") != "true" {
+		return v
+	}
+
+	res := make(map[string]interface{}, len(m))
+	for k, c := range m {
+		switch c.(type) {
+		case map[string]interface{}, []interface{}:
+			res[k] = true
+		default:
+			res[k] = c
+		}
+	}
+	return res
+}
+
+// parseCursor parses the query cursor s, encoded as <json val>[,<json key>].
+func parseCursor(s string) (interface{}, *string, error) {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+
+	var val interface{}
+	if err := dec.Decode(&val); err != nil {
+		return nil, nil, err
+	}
+
+	rest := strings.TrimSpace(s[dec.InputOffset():])
+	if rest == "" {
+		return val, nil, nil
+	}
+	if rest[0] != ',' {
+		return nil, nil, fmt.Errorf("invalid cursor %q", s)
+	}
+
+	var key string
+	if err := json.Unmarshal([]byte(rest[1:]), &key); err != nil {
+		return nil, nil, err
+	}
+	return val, &key, nil
+}
+
+// orderValue returns the value of the child c with key used for ordering by
+// orderBy.
+func orderValue(orderBy, key string, c interface{}) interface{} {
+	switch orderBy {
+	case "$key":
+		return key
+	case "$value":
+		return c
+	}
+
+	for _, seg := range split(orderBy) {
+		m, ok := c.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		c = m[seg]
+	}
+	return c
+}
+
+// compareChildren compares the child c with the ordered value val (and key,
+// when not nil), returning -1, 0, or 1.
+func compareChildren(orderBy string, c child, val interface{}, key *string) int {
+	if orderBy == "$key" {
+		s, _ := val.(string)
+		return compareKeys(c.key, s)
+	}
+
+	if n := compareValues(c.order, val); n != 0 || key == nil {
+		return n
+	}
+	return compareKeys(c.key, *key)
+}
+
+// compareKeys compares the keys a and b in Firebase key order (ie, keys that
+// are 32-bit integers sort numerically before all other keys), returning -1,
+// 0, or 1.
+func compareKeys(a, b string) int {
+	ai, aok := intKey(a)
+	bi, bok := intKey(b)
+	switch {
+	case aok && bok:
+		return cmp(ai < bi, ai > bi)
+	case aok != bok:
+		return cmp(aok, bok)
+	}
+	return cmp(a < b, a > b)
+}
+
+// intKey returns the integer value of key, and whether or not key is a
+// 32-bit integer key.
+func intKey(key string) (int64, bool) {
+	i, err := strconv.ParseInt(key, 10, 64)
+	if err != nil || i < math.MinInt32 || i > math.MaxInt32 || strconv.FormatInt(i, 10) != key {
+		return 0, false
+	}
+	return i, true
+}
+
+// compareValues compares the values a and b in Firebase value order (ie,
+// null, false, true, numbers, strings, and then objects), returning -1, 0, or
+// 1.
+func compareValues(a, b interface{}) int {
+	ra, rb := valueRank(a), valueRank(b)
+	switch {
+	case ra != rb:
+		return cmp(ra < rb, ra > rb)
+	case ra == 3:
+		x, _ := a.(json.Number).Float64()
+		y, _ := b.(json.Number).Float64()
+		return cmp(x < y, x > y)
+	case ra == 4:
+		x, y := a.(string), b.(string)
+		return cmp(x < y, x > y)
+	}
+	return 0
+}
+
+// valueRank returns the rank of the type of v in Firebase value order.
+func valueRank(v interface{}) int {
+	switch x := v.(type) {
+	case nil:
+		return 0
+	case bool:
+		if !x {
+			return 1
+		}
+		return 2
+	case json.Number:
+		return 3
+	case string:
+		return 4
+	}
+	return 5
+}
+
+// cmp returns -1 when less is true, 1 when greater is true, and 0 otherwise.
+func cmp(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
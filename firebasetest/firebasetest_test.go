@@ -0,0 +1,197 @@
+package firebasetest
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/knq/firebase"
+)
+
+func TestServer(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	r := srv.Ref()
+
+	// set and get
+	if err := r.Ref("/a/b").Set(map[string]interface{}{"c": 1, "d": "e"}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	var v map[string]interface{}
+	if err := r.Ref("/a/b").Get(&v); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if exp := map[string]interface{}{"c": json.Number("1"), "d": "e"}; !reflect.DeepEqual(v, exp) {
+		t.Errorf("expected %v, got: %v", exp, v)
+	}
+
+	// update
+	if err := r.Ref("/a").Update(map[string]interface{}{"b/c": 2, "f": true}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s, exp := string(srv.Data("/a")), `{"b":{"c":2,"d":"e"},"f":true}`; s != exp {
+		t.Errorf("expected %s, got: %s", exp, s)
+	}
+
+	// push
+	id, err := r.Ref("/list").Push("x")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if _, err := firebase.PushIDTime(id); err != nil {
+		t.Errorf("expected push id, got: %q (%v)", id, err)
+	}
+
+	// remove
+	if err := r.Ref("/a/b").Remove(); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s, exp := string(srv.Data("/a")), `{"f":true}`; s != exp {
+		t.Errorf("expected %s, got: %s", exp, s)
+	}
+
+	// shallow
+	keys, err := r.GetShallowKeys()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if exp := []string{"a", "list"}; !reflect.DeepEqual(keys, exp) {
+		t.Errorf("expected %v, got: %v", exp, keys)
+	}
+
+	// server values
+	for _, n := range []int64{2, 3} {
+		if err := r.Update(map[string]interface{}{"n": firebase.IncrementInt(n)}); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+	if s := string(srv.Data("/n")); s != "5" {
+		t.Errorf("expected 5, got: %s", s)
+	}
+	var ts firebase.ServerTimestamp
+	if err := r.Ref("/ts").Set(firebase.ServerValueTimestamp); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := r.Ref("/ts").Get(&ts); err != nil || ts.Time().IsZero() {
+		t.Errorf("expected timestamp, got: %v (%v)", ts, err)
+	}
+}
+
+func TestServerQuery(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	if err := srv.SetData("/users", map[string]interface{}{
+		"10": map[string]interface{}{"age": 30},
+		"2":  map[string]interface{}{"age": 20},
+		"b":  map[string]interface{}{"age": 20},
+		"a":  map[string]interface{}{"age": 40},
+		"c":  map[string]interface{}{"name": "none"},
+	}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	r := srv.Ref().Ref("/users")
+	tests := []struct {
+		opts []firebase.QueryOption
+		exp  []string
+	}{
+		{[]firebase.QueryOption{firebase.OrderBy("$key")}, []string{"2", "10", "a", "b", "c"}},
+		{[]firebase.QueryOption{firebase.OrderBy("age")}, []string{"c", "2", "b", "10", "a"}},
+		{[]firebase.QueryOption{firebase.OrderBy("age"), firebase.EqualTo(20)}, []string{"2", "b"}},
+		{[]firebase.QueryOption{firebase.OrderBy("age"), firebase.StartAt(20), firebase.EndAt(30)}, []string{"2", "b", "10"}},
+		{[]firebase.QueryOption{firebase.OrderBy("age"), firebase.StartAfter(20, "2")}, []string{"b", "10", "a"}},
+		{[]firebase.QueryOption{firebase.OrderBy("age"), firebase.EndBefore(30)}, []string{"c", "2", "b"}},
+		{[]firebase.QueryOption{firebase.OrderBy("$key"), firebase.StartAt("a"), firebase.LimitToFirst(2)}, []string{"a", "b"}},
+		{[]firebase.QueryOption{firebase.OrderBy("age"), firebase.LimitToLast(2)}, []string{"10", "a"}},
+	}
+	for i, test := range tests {
+		kvs, err := r.GetOrdered(test.opts...)
+		if err != nil {
+			t.Errorf("test %d expected no error, got: %v", i, err)
+			continue
+		}
+		keys := make([]string, len(kvs))
+		for j, kv := range kvs {
+			keys[j] = kv.Key
+		}
+		if !reflect.DeepEqual(keys, test.exp) {
+			t.Errorf("test %d expected %v, got: %v", i, test.exp, keys)
+		}
+	}
+
+	// filters require orderBy
+	if _, err := r.GetOrdered(firebase.LimitToFirst(1)); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+
+	// shallow cannot be combined with other query parameters
+	for i, opts := range [][]firebase.QueryOption{
+		{firebase.Shallow, firebase.OrderBy("$key")},
+		{firebase.Shallow, firebase.OrderBy("$key"), firebase.LimitToFirst(1)},
+	} {
+		if _, err := r.GetRaw(opts...); !errors.Is(err, firebase.ErrBadRequest) {
+			t.Errorf("test %d expected ErrBadRequest, got: %v", i, err)
+		}
+	}
+
+	// exists
+	for path, exp := range map[string]bool{"/users": true, "/users/a/age": true, "/users/z": false} {
+		ok, err := srv.Ref().Ref(path).Exists()
+		if err != nil || ok != exp {
+			t.Errorf("path %s expected %t, got: %t (%v)", path, exp, ok, err)
+		}
+	}
+}
+
+func TestServerETag(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	r := srv.Ref().Ref("/v")
+
+	var v string
+	etag, err := r.GetWithETag(&v)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if etag != firebase.NullETag {
+		t.Errorf("expected %q, got: %q", firebase.NullETag, etag)
+	}
+
+	// conditional write
+	etag, err = r.SetIfUnchanged(etag, "foo")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if _, err := r.SetIfUnchanged(firebase.NullETag, "bar"); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+	if s := string(srv.Data("/v")); s != `"foo"` {
+		t.Errorf("expected \"foo\", got: %s", s)
+	}
+
+	// conditional read
+	changed, newEtag, err := r.GetIfChanged(etag, &v)
+	if err != nil || changed || newEtag != etag {
+		t.Errorf("expected unchanged, got: %t %q (%v)", changed, newEtag, err)
+	}
+
+	// transaction
+	if err := srv.SetData("/n", 1); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	err = srv.Ref().Ref("/n").Transaction(func(cur json.RawMessage) (interface{}, error) {
+		var n int
+		err := json.Unmarshal(cur, &n)
+		return n + 1, err
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s := string(srv.Data("/n")); s != "2" {
+		t.Errorf("expected 2, got: %s", s)
+	}
+}
@@ -0,0 +1,269 @@
+package firebasetest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/knq/firebase"
+)
+
+// RecorderMode is the mode of a recorder.
+type RecorderMode int
+
+// Recorder modes.
+const (
+	// Record passes requests to the underlying transport, saving each
+	// request's response as a fixture.
+	Record RecorderMode = iota
+
+	// Replay serves responses from saved fixtures, without making any
+	// network requests.
+	Replay
+)
+
+// fixture is a recorded request and response.
+type fixture struct {
+	Method     string      `json:"method"`
+	Path       string      `json:"path"`
+	Query      string      `json:"query"`
+	BodyHash   string      `json:"body_sha256"`
+	StatusCode int         `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body"`
+}
+
+// Recorder is an option that records the responses to requests made with a
+// Firebase database ref as fixtures in dir (in Record mode), or that serves
+// the requests from the fixtures saved in dir (in Replay mode), for running
+// integration tests deterministically, without a live database.
+//
+// Requests are matched by their method, path, query (sorted, and with auth
+// material removed), and the SHA-256 hash of their body. Identical requests
+// are matched in the order they were recorded. In Replay mode, a request
+// without a matching fixture fails with an error. Response bodies are replayed
+// verbatim, and thus the names of pushed nodes are stable across replays.
+//
+// Watches are not recorded, and fail in Replay mode. The Recorder option
+// wraps the transport (see firebase.WrapTransport), and should be passed after
+// any options configuring the transport. As credentials are not recorded,
+// Replay mode should be used without credentials.
+func Recorder(dir string, mode RecorderMode) firebase.Option {
+	return func(r *firebase.DatabaseRef) error {
+		rec := &recorder{
+			dir:    dir,
+			mode:   mode,
+			counts: make(map[string]int),
+		}
+
+		switch mode {
+		case Record:
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
+
+		case Replay:
+			if err := rec.load(); err != nil {
+				return err
+			}
+
+		default:
+			return fmt.Errorf("invalid recorder mode %d", mode)
+		}
+
+		return firebase.WrapTransport(func(base http.RoundTripper) http.RoundTripper {
+			if base == nil {
+				base = http.DefaultTransport
+			}
+			rec.base = base
+			return rec
+		})(r)
+	}
+}
+
+// recorder is a recording and replaying http.RoundTripper.
+type recorder struct {
+	dir  string
+	mode RecorderMode
+	base http.RoundTripper
+
+	mu       sync.Mutex
+	counts   map[string]int
+	fixtures map[string]*fixture
+}
+
+// load loads the fixtures saved in the recorder's dir.
+func (rec *recorder) load() error {
+	files, err := filepath.Glob(filepath.Join(rec.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	rec.fixtures = make(map[string]*fixture, len(files))
+	for _, file := range files {
+		buf, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		f := new(fixture)
+		if err := json.Unmarshal(buf, f); err != nil {
+			return fmt.Errorf("could not decode fixture %s: %v", file, err)
+		}
+		rec.fixtures[strings.TrimSuffix(filepath.Base(file), ".json")] = f
+	}
+
+	return nil
+}
+
+// RoundTrip satisfies the http.RoundTripper interface.
+func (rec *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	stream := strings.Contains(req.Header.Get("Accept"), "text/event-stream")
+	if stream && rec.mode == Record {
+		return rec.base.RoundTrip(req)
+	}
+
+	// build key
+	f, err := newFixture(req)
+	if err != nil {
+		return nil, err
+	}
+	name := rec.next(f)
+
+	if rec.mode == Replay {
+		rec.mu.Lock()
+		saved, ok := rec.fixtures[name]
+		rec.mu.Unlock()
+		if !ok || stream {
+			return nil, fmt.Errorf("firebasetest: no recorded response for %s %s?%s (body sha256 %s)", f.Method, f.Path, f.Query, f.BodyHash)
+		}
+		return saved.response(req), nil
+	}
+
+	// record
+	res, err := rec.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := readBody(res)
+	if err != nil {
+		return nil, err
+	}
+	f.StatusCode, f.Body = res.StatusCode, string(buf)
+	f.Header = make(http.Header)
+	for k, v := range res.Header {
+		switch k {
+		case "Content-Encoding", "Content-Length", "Date", "Set-Cookie":
+		default:
+			f.Header[k] = v
+		}
+	}
+	out, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(rec.dir, name+".json"), append(out, '\n'), 0644); err != nil {
+		return nil, err
+	}
+
+	return f.response(req), nil
+}
+
+// next returns the fixture name for the next occurrence of the request f.
+func (rec *recorder) next(f *fixture) string {
+	key := f.Method + " " + f.Path + "?" + f.Query + " " + f.BodyHash
+	sum := sha256.Sum256([]byte(key))
+	key = hex.EncodeToString(sum[:8])
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	rec.counts[key]++
+	return key + "-" + strconv.Itoa(rec.counts[key])
+}
+
+// newFixture creates the fixture for req, without a response.
+func newFixture(req *http.Request) (*fixture, error) {
+	// strip auth material
+	q := req.URL.Query()
+	q.Del("access_token")
+	q.Del("auth")
+
+	// hash body
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		if req.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				return nil, err
+			}
+			if body, err = ioutil.ReadAll(zr); err != nil {
+				return nil, err
+			}
+		}
+	}
+	sum := sha256.Sum256(body)
+
+	return &fixture{
+		Method:   req.Method,
+		Path:     req.URL.Path,
+		Query:    q.Encode(),
+		BodyHash: hex.EncodeToString(sum[:]),
+	}, nil
+}
+
+// readBody reads and closes the body of res, decompressing the body when gzip
+// compressed.
+func readBody(res *http.Response) ([]byte, error) {
+	defer res.Body.Close()
+
+	buf, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.Header.Get("Content-Encoding") != "gzip" || len(buf) == 0 {
+		return buf, nil
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(zr)
+}
+
+// response creates the recorded response for req.
+func (f *fixture) response(req *http.Request) *http.Response {
+	header := make(http.Header, len(f.Header))
+	for k, v := range f.Header {
+		header[k] = append([]string(nil), v...)
+	}
+
+	return &http.Response{
+		Status:        strconv.Itoa(f.StatusCode) + " " + http.StatusText(f.StatusCode),
+		StatusCode:    f.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(strings.NewReader(f.Body)),
+		ContentLength: int64(len(f.Body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,65 @@
+package firebasetest
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/knq/firebase"
+)
+
+func TestRecorder(t *testing.T) {
+	dir := t.TempDir()
+
+	run := func(r *firebase.DatabaseRef) (string, string, error) {
+		name, err := r.Ref("/list").Push(map[string]interface{}{"v": 1})
+		if err != nil {
+			return "", "", err
+		}
+		var v string
+		if err := r.Ref("/a").Set("foo"); err != nil {
+			return "", "", err
+		}
+		if err := r.Ref("/a").Get(&v); err != nil {
+			return "", "", err
+		}
+		return name, v, nil
+	}
+
+	// record
+	srv := NewServer()
+	name, v, err := run(srv.Ref(firebase.DatabaseSecret("s3cret"), Recorder(dir, Record)))
+	srv.Close()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 3 {
+		t.Fatalf("expected 3 fixtures, got: %d", len(files))
+	}
+	for _, file := range files {
+		buf, _ := ioutil.ReadFile(file)
+		if strings.Contains(string(buf), "s3cret") {
+			t.Errorf("expected auth to be stripped from %s, got: %s", file, string(buf))
+		}
+	}
+
+	// replay (the server is closed)
+	r, err := firebase.NewDatabaseRef(firebase.URL(srv.URL+"/"), Recorder(dir, Replay))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	n, s, err := run(r)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if n != name || s != v {
+		t.Errorf("expected %q and %q, got: %q and %q", name, v, n, s)
+	}
+
+	// unmatched
+	if err := r.Ref("/b").Set("bar"); err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Errorf("expected unmatched request error, got: %v", err)
+	}
+}
@@ -0,0 +1,197 @@
+//go:build go1.18
+
+package firebase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+)
+
+// GetT retrieves the values stored at Firebase database ref r, decoding them
+// into a value of type T.
+//
+// As with Get, numbers are decoded as json.Number values when T (or a field
+// of T) is an interface{} or a json.Number.
+func GetT[T any](r *DatabaseRef, opts ...QueryOption) (T, error) {
+	return GetTContext[T](context.Background(), r, opts...)
+}
+
+// GetTContext retrieves the values stored at Firebase database ref r,
+// decoding them into a value of type T, using the provided context.
+func GetTContext[T any](ctxt context.Context, r *DatabaseRef, opts ...QueryOption) (T, error) {
+	var v T
+	if err := GetContext(ctxt, r, &v, opts...); err != nil {
+		var zero T
+		return zero, err
+	}
+	return v, nil
+}
+
+// GetMapT retrieves the children stored at Firebase database ref r, decoding
+// each child into a value of type T.
+//
+// An empty map is returned when no value is stored at r.
+func GetMapT[T any](r *DatabaseRef, opts ...QueryOption) (map[string]T, error) {
+	return GetMapTContext[T](context.Background(), r, opts...)
+}
+
+// GetMapTContext retrieves the children stored at Firebase database ref r,
+// decoding each child into a value of type T, using the provided context.
+func GetMapTContext[T any](ctxt context.Context, r *DatabaseRef, opts ...QueryOption) (map[string]T, error) {
+	var m map[string]T
+	if err := GetContext(ctxt, r, &m, opts...); err != nil {
+		return nil, err
+	}
+	if m == nil {
+		m = make(map[string]T)
+	}
+	return m, nil
+}
+
+// Prioritized is a value of type T along with its priority, that is encoded
+// as, and decoded from, the {".value": ..., ".priority": ...} shape of the
+// export format.
+//
+// Get, GetStrict and GetT (and their variants) retrieve a Prioritized value in
+// the export format (see FormatExport), so that its priority is decoded along
+// with its value. A Prioritized value can be stored with Set, or passed as the
+// value to SetWithPriority, in which case the passed priority is used.
+//
+// As the export format includes the priority of each descendant with a
+// priority, any descendants of the value having a priority must also be
+// decoded as Prioritized values.
+type Prioritized[T any] struct {
+	// Value is the value.
+	Value T
+
+	// Priority is the priority of the value, and must be a string, a number,
+	// or nil. Numeric priorities are decoded as json.Number values (unless
+	// set otherwise, see DecodeNumbers).
+	Priority interface{}
+}
+
+// MarshalJSON satisfies the json.Marshaler interface.
+func (p Prioritized[T]) MarshalJSON() ([]byte, error) {
+	if err := checkPriority(p.Priority); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(map[string]interface{}{
+		".value":    p.Value,
+		".priority": p.Priority,
+	})
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface.
+//
+// Values without a priority (ie, values not in the export format) are decoded
+// with a nil priority.
+//
+// When a Prioritized value is decoded directly by a database ref (ie, passed
+// to Get, or retrieved with GetT), the ref's decoding settings (see
+// DecodeNumbers, StrictDecode and JSONCodec) apply to the value and priority.
+// Otherwise, such as when nested in another value, UnmarshalJSON decodes with
+// encoding/json, decoding numbers as json.Number values.
+func (p *Prioritized[T]) UnmarshalJSON(buf []byte) error {
+	return p.decodePrioritized(buf, decoder{})
+}
+
+// decodePrioritized satisfies the prioritizedDecoder interface.
+func (p *Prioritized[T]) decodePrioritized(buf []byte, dc decoder) error {
+	var priority interface{}
+
+	buf = bytes.TrimSpace(buf)
+	if len(buf) != 0 && buf[0] == '{' {
+		var m map[string]json.RawMessage
+		if err := json.Unmarshal(buf, &m); err != nil {
+			return err
+		}
+
+		raw, hasPriority := m[".priority"]
+		if hasPriority {
+			if err := dc.decodeFrom(bytes.NewReader(raw), &priority); err != nil {
+				return err
+			}
+			delete(m, ".priority")
+		}
+
+		if v, ok := m[".value"]; ok {
+			buf = v
+		} else if hasPriority {
+			var err error
+			if buf, err = json.Marshal(m); err != nil {
+				return err
+			}
+		}
+	}
+
+	var v T
+	if err := dc.decodeFrom(bytes.NewReader(buf), &v); err != nil {
+		return err
+	}
+
+	p.Value, p.Priority = v, priority
+	return nil
+}
+
+// prioritizedValue satisfies the prioritized interface.
+func (p Prioritized[T]) prioritizedValue() interface{} {
+	return p.Value
+}
+
+// ValueEvent is a value of type T emitted from WatchT.
+type ValueEvent[T any] struct {
+	// Value is the decoded value of the watched ref.
+	Value T
+
+	// Err is the error encountered decoding the value, or the error of the
+	// terminal event ending the watch (see Event.Err).
+	Err error
+}
+
+// WatchT watches Firebase database ref r, decoding the data of put events for
+// the root of the ref (ie, the ref's full value) into a value of type T and
+// emitting it on the returned channel.
+//
+// Put events for child paths, patch events, and keep alive events are not
+// emitted. When the data of an event cannot be decoded, a ValueEvent with Err
+// set is emitted, and the watch continues. The terminal event of the watch is
+// emitted as a ValueEvent with Err set, prior to the channel being closed.
+//
+// See Watch for a description of the watch's lifetime.
+func WatchT[T any](r *DatabaseRef, ctxt context.Context, opts ...QueryOption) (<-chan ValueEvent[T], error) {
+	evs, err := Watch(r, ctxt, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	r.rw.RLock()
+	bufLen := r.watchBufLen
+	r.rw.RUnlock()
+
+	events := make(chan ValueEvent[T], bufLen)
+	go func() {
+		defer close(events)
+
+		for e := range evs {
+			var ve ValueEvent[T]
+			switch {
+			case e.Type == EventTypePut && e.Path == "/":
+				ve.Err = e.Decode(&ve.Value)
+			case isReconnectEvent(e.Type):
+				ve.Err = e.Err()
+			default:
+				continue
+			}
+
+			select {
+			case events <- ve:
+			case <-ctxt.Done():
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
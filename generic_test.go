@@ -0,0 +1,205 @@
+//go:build go1.18
+
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGetT(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/people.json":
+			w.Write([]byte(`{"a":{"name":"amy","age":30},"b":{"name":"bob","age":40}}`))
+		case "/people/a.json":
+			w.Write([]byte(`{"name":"amy","age":30}`))
+		default:
+			w.Write([]byte(`null`))
+		}
+	}))
+	defer srv.Close()
+
+	type person struct {
+		Name string      `json:"name"`
+		Age  json.Number `json:"age"`
+	}
+
+	r := newTestRef(t, srv)
+
+	p, err := GetT[person](r.Ref("/people/a"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if exp := (person{"amy", "30"}); p != exp {
+		t.Errorf("expected %v, got: %v", exp, p)
+	}
+
+	m, err := GetMapT[person](r.Ref("/people"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	exp := map[string]person{"a": {"amy", "30"}, "b": {"bob", "40"}}
+	if !reflect.DeepEqual(m, exp) {
+		t.Errorf("expected %v, got: %v", exp, m)
+	}
+
+	// no value
+	m, err = GetMapT[person](r.Ref("/missing"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if m == nil || len(m) != 0 {
+		t.Errorf("expected empty map, got: %v", m)
+	}
+}
+
+func TestPrioritized(t *testing.T) {
+	type person struct {
+		Name string `json:"name"`
+	}
+
+	// marshal
+	buf, err := json.Marshal(Prioritized[person]{person{"amy"}, 2})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s, exp := string(buf), `{".priority":2,".value":{"name":"amy"}}`; s != exp {
+		t.Errorf("expected %s, got: %s", exp, s)
+	}
+	if _, err := json.Marshal(Prioritized[string]{"x", true}); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+
+	// unmarshal
+	var p Prioritized[person]
+	if err := json.Unmarshal([]byte(`{"name":"amy",".priority":"a"}`), &p); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if exp := (Prioritized[person]{person{"amy"}, "a"}); p != exp {
+		t.Errorf("expected %v, got: %v", exp, p)
+	}
+	var s Prioritized[string]
+	if err := json.Unmarshal([]byte(`{".value":"x",".priority":1.5}`), &s); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if exp := (Prioritized[string]{"x", json.Number("1.5")}); s != exp {
+		t.Errorf("expected %v, got: %v", exp, s)
+	}
+	if err := json.Unmarshal([]byte(`"y"`), &s); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if exp := (Prioritized[string]{"y", nil}); s != exp {
+		t.Errorf("expected %v, got: %v", exp, s)
+	}
+
+	// get and set
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != "GET" {
+			body, _ = ioutil.ReadAll(req.Body)
+			w.Write([]byte(`null`))
+			return
+		}
+		if req.URL.Query().Get("format") != "export" {
+			w.Write([]byte(`{"name":"amy"}`))
+			return
+		}
+		w.Write([]byte(`{"name":"amy",".priority":2}`))
+	}))
+	defer srv.Close()
+
+	r := newTestRef(t, srv)
+	p, err = GetT[Prioritized[person]](r)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if exp := (Prioritized[person]{person{"amy"}, json.Number("2")}); p != exp {
+		t.Errorf("expected %v, got: %v", exp, p)
+	}
+
+	// decoded with the ref's decoding settings
+	if err := r.Get(&p, DecodeNumbers(AsFloat64)); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if exp := (Prioritized[person]{person{"amy"}, float64(2)}); p != exp {
+		t.Errorf("expected %v, got: %v", exp, p)
+	}
+	var other Prioritized[struct {
+		Other string `json:"other"`
+	}]
+	if err := r.Get(&other, StrictDecode); err == nil || !strings.Contains(err.Error(), `unknown field "name"`) {
+		t.Errorf("expected unknown field error, got: %v", err)
+	}
+	var codec testCodec
+	if _, err := GetT[Prioritized[person]](newTestRef(t, srv, JSONCodec(&codec))); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if n := atomic.LoadInt32(&codec.unmarshals); n != 2 {
+		t.Errorf("expected value and priority decoded with the codec, got %d unmarshals", n)
+	}
+
+	if err := r.SetWithPriority(Prioritized[string]{"x", 1}, "p"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s, exp := string(body), `{".priority":"p",".value":"x"}`; s != exp {
+		t.Errorf("expected %s, got: %s", exp, s)
+	}
+	if err := r.Set(Prioritized[string]{"x", 1}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s, exp := strings.TrimSpace(string(body)), `{".priority":1,".value":"x"}`; s != exp {
+		t.Errorf("expected %s, got: %s", exp, s)
+	}
+}
+
+func TestWatchT(t *testing.T) {
+	srv := newStreamServer(t, ""+
+		"event: put\n"+
+		"data: {\"path\":\"/\",\"data\":{\"a\":1}}\n"+
+		"\n"+
+		"event: put\n"+
+		"data: {\"path\":\"/a\",\"data\":2}\n"+
+		"\n"+
+		"event: keep-alive\n"+
+		"data: null\n"+
+		"\n"+
+		"event: put\n"+
+		"data: {\"path\":\"/\",\"data\":{\"a\":3}}\n"+
+		"\n",
+	)
+	defer srv.Close()
+
+	ctxt, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	evs, err := WatchT[map[string]int](newTestRef(t, srv), ctxt)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	var vals []map[string]int
+	var errs int
+	for e := range evs {
+		if e.Err != nil {
+			errs++
+			continue
+		}
+		vals = append(vals, e.Value)
+	}
+
+	exp := []map[string]int{{"a": 1}, {"a": 3}}
+	if !reflect.DeepEqual(vals, exp) {
+		t.Errorf("expected %v, got: %v", exp, vals)
+	}
+	if errs != 1 {
+		t.Errorf("expected 1 terminal error, got: %d", errs)
+	}
+}
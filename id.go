@@ -1,7 +1,9 @@
 package firebase
 
 import (
+	"fmt"
 	"math/rand"
+	"strings"
 	"sync"
 	"time"
 )
@@ -56,7 +58,9 @@ func (ig *IDGen) GeneratePushID() string {
 
 	id := make([]byte, 20)
 
-	// grab last characters
+	// grab last characters, incrementing the last characters when called
+	// multiple times within the same millisecond, otherwise generating new
+	// random characters
 	ig.mu.Lock()
 	now := time.Now().UTC().UnixNano() / 1e6
 	if ig.stamp == now {
@@ -67,6 +71,10 @@ func (ig *IDGen) GeneratePushID() string {
 			}
 			ig.last[i] = 0
 		}
+	} else {
+		for i = 0; i < 12; i++ {
+			ig.last[i] = ig.r.Intn(64)
+		}
 	}
 	ig.stamp = now
 
@@ -85,6 +93,29 @@ func (ig *IDGen) GeneratePushID() string {
 	return string(id)
 }
 
+// PushIDTime returns the creation time encoded in the first 8 characters of
+// the push ID id.
+func PushIDTime(id string) (time.Time, error) {
+	if len(id) != 20 {
+		return time.Time{}, fmt.Errorf("push id must be 20 characters, got: %d", len(id))
+	}
+
+	var ms int64
+	for i := 0; i < 20; i++ {
+		n := strings.IndexByte(defaultPushIDChars, id[i])
+		if n == -1 {
+			return time.Time{}, fmt.Errorf("push id contains invalid character %q", id[i])
+		}
+
+		// decode timestamp
+		if i < 8 {
+			ms = ms*64 + int64(n)
+		}
+	}
+
+	return time.Unix(0, ms*int64(time.Millisecond)), nil
+}
+
 func init() {
 	// set default id generator
 	ig, err := NewPushIDGenerator(nil)
@@ -1,9 +1,12 @@
 package firebase
 
 import (
+	"math/rand"
+	"sort"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestGeneratePushID(t *testing.T) {
@@ -56,3 +59,45 @@ func TestGeneratePushIDMany(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestGeneratePushIDOrder(t *testing.T) {
+	ig, err := NewPushIDGenerator(rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make([]string, 10000)
+	for i := range ids {
+		ids[i] = ig.GeneratePushID()
+		for _, c := range ids[i] {
+			if !strings.ContainsRune(defaultPushIDChars, c) {
+				t.Fatalf("id %s contains invalid character %c", ids[i], c)
+			}
+		}
+	}
+
+	if !sort.StringsAreSorted(ids) {
+		t.Errorf("ids should be lexicographically sorted in generation order")
+	}
+}
+
+func TestPushIDTime(t *testing.T) {
+	before := time.Now()
+	id := GeneratePushID()
+	after := time.Now()
+
+	ts, err := PushIDTime(id)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if ts.Before(before.Truncate(time.Millisecond)) || ts.After(after) {
+		t.Errorf("expected decoded time %s to be between %s and %s", ts, before, after)
+	}
+
+	for i, id := range []string{"", "-KXYZ", "-KXYZabcdefghijklmnopq", "-KXYZ!bcdefghijklmno"} {
+		if _, err := PushIDTime(id); err == nil {
+			t.Errorf("test %d expected error for %q", i, id)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// DefaultImportMaxBytes is the default maximum size of the payload of
+	// each request made by Import.
+	DefaultImportMaxBytes = 1024 * 1024
+)
+
+// importConfig holds the configuration of an import.
+type importConfig struct {
+	maxBytes int
+	replace  bool
+}
+
+// ImportOption is an option to modify an import.
+type ImportOption func(i *importConfig) error
+
+// ImportMaxBytes is an import option that sets the maximum size of the
+// payload of each request made by the import. Children larger than n are
+// written with a request of their own.
+func ImportMaxBytes(n int) ImportOption {
+	return func(i *importConfig) error {
+		if n < 1 {
+			return fmt.Errorf("import max bytes must be greater than 0, got: %d", n)
+		}
+
+		i.maxBytes = n
+		return nil
+	}
+}
+
+// ImportMerge is an import option that merges the imported children with the
+// values stored at the ref, replacing only the children listed in the
+// imported document, and leaving the remaining children untouched. This is
+// the default.
+func ImportMerge(i *importConfig) error {
+	i.replace = false
+	return nil
+}
+
+// ImportReplace is an import option that replaces the values stored at the
+// ref with the imported document, by removing the values stored at the ref
+// before writing the imported children.
+//
+// As the values are removed before the document is read, a malformed
+// document leaves the ref with only the children read before the error.
+func ImportReplace(i *importConfig) error {
+	i.replace = true
+	return nil
+}
+
+// ImportError is the error returned when a request made by an import failed.
+type ImportError struct {
+	// Keys are the top-level keys written by the failed request.
+	Keys []string
+
+	// Err is the error of the failed request.
+	Err error
+}
+
+// Error satisfies the error interface.
+func (e *ImportError) Error() string {
+	return fmt.Sprintf("firebase: could not import key(s) %s: %v", strings.Join(e.Keys, ", "), e.Err)
+}
+
+// Unwrap returns the error of the failed request.
+func (e *ImportError) Unwrap() error {
+	return e.Err
+}
+
+// Import reads a JSON document (an object) from src, and writes it to
+// Firebase database ref r, splitting the top-level children of the document
+// into multiple requests, with payloads of at most DefaultImportMaxBytes
+// (see ImportMaxBytes), instead of a single large Set.
+//
+// The document is read one top-level child at a time, and is never held in
+// memory in its entirety. When a request fails, an *ImportError identifying
+// the failed top-level keys is returned, and the children written by the
+// preceding requests remain written.
+func Import(r *DatabaseRef, src io.Reader, opts ...ImportOption) error {
+	return ImportContext(context.Background(), r, src, opts...)
+}
+
+// ImportContext reads a JSON document from src, and writes it to Firebase
+// database ref r, using the provided context.
+func ImportContext(ctxt context.Context, r *DatabaseRef, src io.Reader, opts ...ImportOption) error {
+	var err error
+
+	// apply opts
+	i := &importConfig{
+		maxBytes: DefaultImportMaxBytes,
+	}
+	for _, o := range opts {
+		err = o(i)
+		if err != nil {
+			return err
+		}
+	}
+
+	dec := json.NewDecoder(src)
+	tok, err := dec.Token()
+	if err != nil {
+		return &Error{
+			Err: fmt.Sprintf("could not unmarshal json: %v", err),
+			err: err,
+		}
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return &Error{
+			Err: "import document must be a JSON object",
+		}
+	}
+
+	if i.replace {
+		if err = RemoveContext(ctxt, r); err != nil {
+			return err
+		}
+	}
+
+	// write batches of children
+	batch, size := make(map[string]json.RawMessage), 2
+	var keys []string
+	flush := func() error {
+		if len(keys) == 0 {
+			return nil
+		}
+		if err := UpdateContext(ctxt, r, batch, PrintSilent); err != nil {
+			return &ImportError{Keys: keys, Err: err}
+		}
+		batch, size, keys = make(map[string]json.RawMessage), 2, nil
+		return nil
+	}
+
+	for dec.More() {
+		tok, err = dec.Token()
+		if err != nil {
+			return &Error{
+				Err: fmt.Sprintf("could not unmarshal json: %v", err),
+				err: err,
+			}
+		}
+		key := tok.(string)
+		if key != ".priority" {
+			if err = checkKey(key); err != nil {
+				return err
+			}
+		}
+
+		var raw json.RawMessage
+		if err = dec.Decode(&raw); err != nil {
+			return &Error{
+				Err: fmt.Sprintf("could not unmarshal json: %v", err),
+				err: err,
+			}
+		}
+
+		// size of "key":value, in the encoded payload
+		k, _ := json.Marshal(key)
+		n := len(k) + len(raw) + 2
+
+		// write large children on their own
+		if n+2 > i.maxBytes {
+			if err = SetContext(ctxt, r.Ref(key), []byte(raw), PrintSilent); err != nil {
+				return &ImportError{Keys: []string{key}, Err: err}
+			}
+			continue
+		}
+
+		if _, ok := batch[key]; !ok {
+			if size+n > i.maxBytes {
+				if err = flush(); err != nil {
+					return err
+				}
+			}
+			keys = append(keys, key)
+		}
+		batch[key], size = raw, size+n
+	}
+
+	if _, err = dec.Token(); err != nil {
+		return &Error{
+			Err: fmt.Sprintf("could not unmarshal json: %v", err),
+			err: err,
+		}
+	}
+
+	return flush()
+}
+
+// Import reads a JSON document from src, and writes it to the Firebase
+// database ref, splitting the top-level children of the document into
+// multiple requests.
+func (r *DatabaseRef) Import(src io.Reader, opts ...ImportOption) error {
+	return Import(r, src, opts...)
+}
+
+// ImportContext reads a JSON document from src, and writes it to the Firebase
+// database ref, using the provided context.
+func (r *DatabaseRef) ImportContext(ctxt context.Context, src io.Reader, opts ...ImportOption) error {
+	return ImportContext(ctxt, r, src, opts...)
+}
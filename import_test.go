@@ -0,0 +1,65 @@
+package firebase_test
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/knq/firebase"
+	"github.com/knq/firebase/firebasetest"
+)
+
+func TestImport(t *testing.T) {
+	srv := firebasetest.NewServer()
+	defer srv.Close()
+
+	doc := `{"a":1,"b":"two","c":{"d":true},"e":"` + strings.Repeat("x", 64) + `"}`
+
+	tests := []struct {
+		opts     []firebase.ImportOption
+		requests int
+		exp      string
+	}{
+		{nil, 1, `{"a":1,"b":"two","c":{"d":true},"e":"` + strings.Repeat("x", 64) + `","z":0}`},
+		{[]firebase.ImportOption{firebase.ImportMaxBytes(24)}, 3, `{"a":1,"b":"two","c":{"d":true},"e":"` + strings.Repeat("x", 64) + `","z":0}`},
+		{[]firebase.ImportOption{firebase.ImportReplace}, 2, `{"a":1,"b":"two","c":{"d":true},"e":"` + strings.Repeat("x", 64) + `"}`},
+	}
+	for i, test := range tests {
+		if err := srv.SetData("/", map[string]interface{}{"users": map[string]interface{}{"a": 0, "z": 0}}); err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		d := firebasetest.NewRecordingDoer(srv.Client())
+		r := srv.Ref(firebase.HTTPDoer(d)).Ref("/users")
+		if err := r.Import(strings.NewReader(doc), test.opts...); err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		if n := len(d.Requests()); n != test.requests {
+			t.Errorf("test %d expected %d requests, got: %d", i, test.requests, n)
+		}
+		if s := string(srv.Data("/users")); s != test.exp {
+			t.Errorf("test %d expected %s, got: %s", i, test.exp, s)
+		}
+	}
+
+	// invalid documents
+	r := srv.Ref().Ref("/users")
+	for i, doc := range []string{`[1,2]`, `{"a":1`, `{"a/b":1}`} {
+		if err := r.Import(strings.NewReader(doc)); err == nil {
+			t.Errorf("test %d expected error, got nil", i)
+		}
+	}
+
+	// failed key
+	r = srv.Ref(firebase.Transport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == "/users/e.json" {
+			return nil, errors.New("failed")
+		}
+		return srv.Client().Transport.RoundTrip(req)
+	}))).Ref("/users")
+	err := r.Import(strings.NewReader(doc), firebase.ImportMaxBytes(24))
+	var e *firebase.ImportError
+	if !errors.As(err, &e) || len(e.Keys) != 1 || e.Keys[0] != "e" {
+		t.Errorf("expected import error for key e, got: %v", err)
+	}
+}
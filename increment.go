@@ -0,0 +1,59 @@
+package firebase
+
+import (
+	"strconv"
+)
+
+// ServerIncrement provides a json.Marshal'able type for atomically
+// incrementing a numeric value stored in Firebase by a delta.
+//
+// When serialized to Firebase, Firebase adds the delta to the currently stored
+// value (treating a missing or non-numeric value as 0), without the value
+// first being read. ServerIncrement can be used as a value in a map or struct
+// passed to Update, Push or Set (ie, map[string]interface{}{"count":
+// firebase.Increment(1)}).
+//
+// NOTE: Firebase rejects increments as the root value of a write, so a
+// ServerIncrement passed directly to Set returns an error. Instead, use Update
+// on the parent ref.
+type ServerIncrement struct {
+	delta string
+}
+
+// Increment creates a server increment for the (floating point) delta.
+func Increment(delta float64) ServerIncrement {
+	return ServerIncrement{delta: strconv.FormatFloat(delta, 'g', -1, 64)}
+}
+
+// IncrementInt creates a server increment for the integer delta, retaining
+// the full precision of deltas that cannot be represented as a float64.
+func IncrementInt(delta int64) ServerIncrement {
+	return ServerIncrement{delta: strconv.FormatInt(delta, 10)}
+}
+
+// Delta returns the JSON number representation of the increment's delta.
+func (si ServerIncrement) Delta() string {
+	if si.delta == "" {
+		return "0"
+	}
+	return si.delta
+}
+
+// MarshalJSON satisfies the json.Marshaler interface.
+func (si ServerIncrement) MarshalJSON() ([]byte, error) {
+	return []byte(`{".sv":{"increment":` + si.Delta() + `}}`), nil
+}
+
+// String satisfies the fmt.Stringer interface.
+func (si ServerIncrement) String() string {
+	return "increment(" + si.Delta() + ")"
+}
+
+// isServerIncrement returns true when v is a ServerIncrement.
+func isServerIncrement(v interface{}) bool {
+	switch v.(type) {
+	case ServerIncrement, *ServerIncrement:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package firebase
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIncrement(t *testing.T) {
+	tests := []struct {
+		v   ServerIncrement
+		exp string
+	}{
+		{Increment(1), `{".sv":{"increment":1}}`},
+		{Increment(-2.5), `{".sv":{"increment":-2.5}}`},
+		{IncrementInt(9007199254740993), `{".sv":{"increment":9007199254740993}}`},
+		{ServerIncrement{}, `{".sv":{"increment":0}}`},
+	}
+	for i, test := range tests {
+		buf, err := json.Marshal(test.v)
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		if string(buf) != test.exp {
+			t.Errorf("test %d expected %s, got: %s", i, test.exp, string(buf))
+		}
+	}
+}
+
+func TestIncrementUpdate(t *testing.T) {
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		buf, _ := ioutil.ReadAll(req.Body)
+		body = string(buf)
+		w.Write([]byte(`{"count":1}`))
+	}))
+	defer srv.Close()
+
+	r := newTestRef(t, srv)
+
+	err := r.Update(map[string]interface{}{"count": Increment(1)})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if exp := `{"count":{".sv":{"increment":1}}}`; strings.TrimSpace(body) != exp {
+		t.Errorf("expected %s, got: %s", exp, body)
+	}
+
+	// root of set
+	body = ""
+	for _, v := range []interface{}{Increment(1), &ServerIncrement{}} {
+		if err := r.Ref("/count").Set(v); err == nil {
+			t.Errorf("expected error for %v", v)
+		}
+	}
+	if body != "" {
+		t.Errorf("expected no request, got: %s", body)
+	}
+}
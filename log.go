@@ -0,0 +1,125 @@
+package firebase
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// requestLog is the request logging configuration of a database ref.
+type requestLog struct {
+	logf Logf
+
+	// bodyMax is the maximum number of bytes of request and response bodies
+	// to log, or 0 when bodies are not logged.
+	bodyMax int
+}
+
+// countingBody is a response body that counts the number of bytes read,
+// retaining the first max bytes.
+type countingBody struct {
+	io.ReadCloser
+	n   int64
+	max int
+	buf bytes.Buffer
+}
+
+// Read satisfies the io.Reader interface.
+func (cb *countingBody) Read(p []byte) (int, error) {
+	n, err := cb.ReadCloser.Read(p)
+	cb.n += int64(n)
+	if rem := cb.max - cb.buf.Len(); rem > 0 {
+		if rem > n {
+			rem = n
+		}
+		cb.buf.Write(p[:rem])
+	}
+	return n, err
+}
+
+// Close satisfies the io.Closer interface, reading (and counting) any
+// remaining body prior to closing the body.
+func (cb *countingBody) Close() error {
+	io.Copy(ioutil.Discard, io.LimitReader(cb, maxDrain))
+	return cb.ReadCloser.Close()
+}
+
+// maxDrain is the maximum number of bytes read from an unread response body
+// when it is closed.
+const maxDrain = 256 << 10
+
+// countBody wraps the body of res with a counting body, retaining the first
+// max bytes.
+func countBody(res *http.Response, max int) *countingBody {
+	cb := &countingBody{ReadCloser: res.Body, max: max}
+	res.Body = cb
+	return cb
+}
+
+// request logs the request req, its response res (if any) and the
+// (redacted) error err, and the elapsed time since start.
+func (rl requestLog) request(req *http.Request, res *http.Response, cb *countingBody, start time.Time, err error) {
+	if rl.logf == nil || req == nil {
+		return
+	}
+
+	var resp string
+	switch {
+	case err != nil && res == nil:
+		resp = "error: " + redact(err.Error())
+	case res != nil:
+		resp = res.Status
+		if cb != nil {
+			resp += " " + formatSize(cb.n)
+		}
+	}
+	rl.logf("firebase: %s %s %s -> %s (%s)", req.Method, redact(req.URL.String()), formatSize(req.ContentLength), resp, time.Since(start).Round(time.Microsecond))
+
+	if rl.bodyMax <= 0 {
+		return
+	}
+
+	// request body
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			buf, _ := ioutil.ReadAll(io.LimitReader(body, int64(rl.bodyMax)))
+			body.Close()
+			if len(buf) != 0 {
+				rl.logf("firebase: request body: %s", redact(string(buf)))
+			}
+		}
+	}
+
+	// response body
+	if cb != nil && cb.buf.Len() != 0 {
+		rl.logf("firebase: response body: %s", cb.buf.String())
+	}
+}
+
+// formatSize formats the size n in bytes, or "-" when the size is unknown.
+func formatSize(n int64) string {
+	if n < 0 {
+		return "-"
+	}
+	return strconv.FormatInt(n, 10) + "B"
+}
+
+// MetricsHook is the interface for collecting metrics of the requests made
+// with a database ref (see the Metrics option).
+type MetricsHook interface {
+	// RequestDone is called once for each attempt of a request, with the
+	// request's method and database path, the response status (or 0 when no
+	// response was received), the duration of the attempt, the sizes of the
+	// request and response bodies (or -1 when unknown), and the error of the
+	// attempt, if any.
+	RequestDone(method, path string, status int, duration time.Duration, reqBytes, respBytes int64, err error)
+}
+
+// NoopMetrics is a MetricsHook that discards all metrics.
+type NoopMetrics struct{}
+
+// RequestDone satisfies the MetricsHook interface.
+func (NoopMetrics) RequestDone(string, string, int, time.Duration, int64, int64, error) {}
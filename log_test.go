@@ -0,0 +1,110 @@
+package firebase
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLogRequests(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Accept") == "text/event-stream" {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("event: put\ndata: {\"path\":\"/\",\"data\":1}\n\n"))
+			return
+		}
+		w.Write([]byte(`{"a":"bcdefghij"}`))
+	}))
+	defer srv.Close()
+
+	var mu sync.Mutex
+	var lines []string
+	logf := func(s string, v ...interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		lines = append(lines, fmt.Sprintf(s, v...))
+	}
+
+	r := newTestRef(t, srv, DatabaseSecret("s3cr3t"), LogRequests(logf), LogRequestBodies(8))
+	if err := r.Ref("/a").Set(map[string]string{"a": "bcdefghij"}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	evs, err := r.Watch(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	for range evs {
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	exp := []*regexp.Regexp{
+		regexp.MustCompile(`^firebase: PUT http://127\.0\.0\.1:\d+/a\.json\?auth=REDACTED 17B -> 200 OK 17B \(.+\)$`),
+		regexp.MustCompile(`^firebase: request body: \{"a":"bc$`),
+		regexp.MustCompile(`^firebase: response body: \{"a":"bc$`),
+		regexp.MustCompile(`^firebase: GET http://127\.0\.0\.1:\d+/\.json\?auth=REDACTED 0B -> 200 OK \(.+\)$`),
+		regexp.MustCompile(`^firebase: watch GET http://127\.0\.0\.1:\d+/\.json\?auth=REDACTED ended after 2 events: closed: connection closed$`),
+	}
+	if len(lines) != len(exp) {
+		t.Fatalf("expected %d log lines, got: %q", len(exp), lines)
+	}
+	for i, re := range exp {
+		if !re.MatchString(lines[i]) {
+			t.Errorf("line %d expected to match %s, got: %s", i, re, lines[i])
+		}
+		if strings.Contains(lines[i], "s3cr3t") {
+			t.Errorf("line %d expected auth to be redacted, got: %s", i, lines[i])
+		}
+	}
+}
+
+type testMetrics struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (m *testMetrics) RequestDone(method, path string, status int, _ time.Duration, reqBytes, respBytes int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, fmt.Sprintf("%s %s %d %d %d %t", method, path, status, reqBytes, respBytes, err != nil))
+}
+
+func TestMetrics(t *testing.T) {
+	var count int32
+	srv := newThrottleServer(2, &count)
+	defer srv.Close()
+
+	m := new(testMetrics)
+	r := newTestRef(t, srv, RetryOnThrottle(3), Metrics(m))
+	if err := r.Ref("/a/b").Set(1); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if n := atomic.LoadInt32(&count); n != 3 {
+		t.Fatalf("expected 3 requests, got: %d", n)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	exp := []string{
+		"PUT /a/b 429 1 0 true",
+		"PUT /a/b 429 1 0 true",
+		"PUT /a/b 200 1 16 false",
+	}
+	if len(m.calls) != len(exp) {
+		t.Fatalf("expected %d calls, got: %q", len(exp), m.calls)
+	}
+	for i, s := range exp {
+		if m.calls[i] != s {
+			t.Errorf("call %d expected %q, got: %q", i, s, m.calls[i])
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// queryString returns the encoded query string for a request built on a test
+// database ref with opts.
+func queryString(t *testing.T, opts ...QueryOption) (string, error) {
+	r, err := NewDatabaseRef(URL("https://example.firebaseio.com/"))
+	if err != nil {
+		t.Fatalf("could not create database ref: %v", err)
+	}
+
+	req, cancel, err := r.createRequest(context.Background(), "GET", nil, opts...)
+	if err != nil {
+		return "", err
+	}
+	cancel()
+
+	return req.URL.RawQuery, nil
+}
+
+func TestOrderByQueryOptions(t *testing.T) {
+	tests := []struct {
+		opts []QueryOption
+		exp  string
+	}{
+		{[]QueryOption{OrderBy("name")}, `orderBy=%22name%22`},
+		{[]QueryOption{OrderBy("$key"), StartAt("foo")}, `orderBy=%22%24key%22&startAt=%22foo%22`},
+		{[]QueryOption{OrderBy("age"), StartAt(18)}, `orderBy=%22age%22&startAt=18`},
+		{[]QueryOption{OrderBy("height"), EndAt(1.85)}, `endAt=1.85&orderBy=%22height%22`},
+		{[]QueryOption{OrderBy("active"), EqualTo(true)}, `equalTo=true&orderBy=%22active%22`},
+		{[]QueryOption{OrderBy("deleted"), EqualTo(nil)}, `equalTo=null&orderBy=%22deleted%22`},
+		{[]QueryOption{OrderBy("$value"), StartAt(1), EndAt(5)}, `endAt=5&orderBy=%22%24value%22&startAt=1`},
+	}
+
+	for i, test := range tests {
+		q, err := queryString(t, test.opts...)
+		if err != nil {
+			t.Errorf("test %d expected no error, got: %v", i, err)
+			continue
+		}
+		if q != test.exp {
+			t.Errorf("test %d expected %s, got: %s", i, test.exp, q)
+		}
+	}
+}
+
+func TestQueryValues(t *testing.T) {
+	q, err := queryString(t, QueryValues(func(v url.Values) error {
+		v.Set("foo", "bar")
+		return nil
+	}), PrintPretty)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if exp := "foo=bar&print=pretty"; q != exp {
+		t.Errorf("expected %s, got: %s", exp, q)
+	}
+}
+
+func TestOrderByRequired(t *testing.T) {
+	tests := []struct {
+		opt QueryOption
+		exp string
+	}{
+		{StartAt("a"), "startAt"},
+		{EndAt(1), "endAt"},
+		{EqualTo(false), "equalTo"},
+	}
+
+	for i, test := range tests {
+		_, err := queryString(t, test.opt)
+		if err == nil {
+			t.Errorf("test %d expected error", i)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.exp) || !strings.Contains(err.Error(), "orderBy") {
+			t.Errorf("test %d expected error to mention %s and orderBy, got: %v", i, test.exp, err)
+		}
+	}
+}
+
+func TestLimitQueryOptions(t *testing.T) {
+	tests := []struct {
+		opts []QueryOption
+		exp  string
+	}{
+		{[]QueryOption{OrderBy("$key"), LimitToFirst(10)}, `limitToFirst=10&orderBy=%22%24key%22`},
+		{[]QueryOption{OrderBy("$key"), LimitToLast(1)}, `limitToLast=1&orderBy=%22%24key%22`},
+		{[]QueryOption{OrderBy("age"), StartAt(18), LimitToFirst(5)}, `limitToFirst=5&orderBy=%22age%22&startAt=18`},
+		{[]QueryOption{OrderBy("age"), EndAt(65), LimitToLast(3)}, `endAt=65&limitToLast=3&orderBy=%22age%22`},
+	}
+
+	for i, test := range tests {
+		q, err := queryString(t, test.opts...)
+		if err != nil {
+			t.Errorf("test %d expected no error, got: %v", i, err)
+			continue
+		}
+		if q != test.exp {
+			t.Errorf("test %d expected %s, got: %s", i, test.exp, q)
+		}
+	}
+}
+
+func TestLimitQueryOptionsErrors(t *testing.T) {
+	tests := [][]QueryOption{
+		{OrderBy("$key"), LimitToFirst(0)},
+		{OrderBy("$key"), LimitToFirst(-1)},
+		{OrderBy("$key"), LimitToLast(0)},
+		{OrderBy("$key"), LimitToLast(-5)},
+		{OrderBy("$key"), LimitToFirst(1), LimitToLast(1)},
+		{LimitToFirst(1)},
+	}
+
+	for i, opts := range tests {
+		if _, err := queryString(t, opts...); err == nil {
+			t.Errorf("test %d expected error", i)
+		}
+	}
+}
+
+func TestCursorQueryOptions(t *testing.T) {
+	tests := []struct {
+		opts []QueryOption
+		exp  string
+	}{
+		{[]QueryOption{OrderBy("$key"), StartAfter("foo")}, `orderBy=%22%24key%22&startAfter=%22foo%22`},
+		{[]QueryOption{OrderBy("age"), EndBefore(65)}, `endBefore=65&orderBy=%22age%22`},
+		{[]QueryOption{OrderBy("age"), StartAfter(18, "bob")}, `orderBy=%22age%22&startAfter=18%2C%22bob%22`},
+		{[]QueryOption{OrderBy("active"), EndBefore(true, "z"), LimitToLast(2)}, `endBefore=true%2C%22z%22&limitToLast=2&orderBy=%22active%22`},
+		{[]QueryOption{OrderBy("age"), StartAt(18), EndBefore(65)}, `endBefore=65&orderBy=%22age%22&startAt=18`},
+	}
+
+	for i, test := range tests {
+		q, err := queryString(t, test.opts...)
+		if err != nil {
+			t.Errorf("test %d expected no error, got: %v", i, err)
+			continue
+		}
+		if q != test.exp {
+			t.Errorf("test %d expected %s, got: %s", i, test.exp, q)
+		}
+	}
+}
+
+func TestCursorQueryOptionsErrors(t *testing.T) {
+	tests := [][]QueryOption{
+		{StartAfter(1)},
+		{OrderBy("age"), StartAt(1), StartAfter(1)},
+		{OrderBy("age"), EndAt(1), EndBefore(1)},
+		{OrderBy("age"), StartAfter(1, "a", "b")},
+	}
+
+	for i, opts := range tests {
+		if _, err := queryString(t, opts...); err == nil {
+			t.Errorf("test %d expected error", i)
+		}
+	}
+}
+
+func TestServerTimeout(t *testing.T) {
+	tests := []struct {
+		d   time.Duration
+		exp string
+	}{
+		{time.Millisecond, "timeout=1ms"},
+		{1500 * time.Millisecond, "timeout=1500ms"},
+		{15 * time.Second, "timeout=15s"},
+		{90 * time.Second, "timeout=90s"},
+		{2 * time.Minute, "timeout=2min"},
+		{MaxServerTimeout, "timeout=15min"},
+	}
+
+	for i, test := range tests {
+		q, err := queryString(t, ServerTimeout(test.d))
+		if err != nil {
+			t.Errorf("test %d expected no error, got: %v", i, err)
+			continue
+		}
+		if q != test.exp {
+			t.Errorf("test %d expected %s, got: %s", i, test.exp, q)
+		}
+	}
+
+	for i, d := range []time.Duration{0, time.Microsecond, -time.Second, MaxServerTimeout + time.Millisecond} {
+		if _, err := queryString(t, ServerTimeout(d)); err == nil {
+			t.Errorf("test %d expected error for %s", i, d)
+		}
+	}
+
+	// combined with a client-side timeout
+	q, err := queryString(t, ServerTimeout(5*time.Second), Timeout(10*time.Second))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if q != "timeout=5s" {
+		t.Errorf("expected timeout=5s, got: %s", q)
+	}
+}
+
+func TestWriteSizeLimit(t *testing.T) {
+	for _, level := range []string{
+		WriteSizeLimitTiny, WriteSizeLimitSmall, WriteSizeLimitMedium,
+		WriteSizeLimitLarge, WriteSizeLimitUnlimited,
+	} {
+		q, err := queryString(t, WriteSizeLimit(level))
+		if err != nil {
+			t.Errorf("level %s expected no error, got: %v", level, err)
+			continue
+		}
+		if exp := "writeSizeLimit=" + level; q != exp {
+			t.Errorf("level %s expected %s, got: %s", level, exp, q)
+		}
+	}
+
+	for _, level := range []string{"", "huge", "Tiny"} {
+		if _, err := queryString(t, WriteSizeLimit(level)); err == nil {
+			t.Errorf("level %q expected error", level)
+		}
+	}
+}
+
+func TestAuthOverride(t *testing.T) {
+	override := map[string]interface{}{
+		"uid": "user1",
+		"token": map[string]interface{}{
+			"admin":  true,
+			"groups": []interface{}{"a", "b&c"},
+		},
+	}
+
+	qs, err := queryString(t, AuthUID("other"), AuthOverride(override))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	v, err := url.ParseQuery(qs)
+	if err != nil {
+		t.Fatalf("could not parse query: %v", err)
+	}
+	if n := len(v["auth_variable_override"]); n != 1 {
+		t.Fatalf("expected 1 auth_variable_override, got: %d", n)
+	}
+	var got map[string]interface{}
+	if err = json.Unmarshal([]byte(v.Get("auth_variable_override")), &got); err != nil {
+		t.Fatalf("could not unmarshal auth_variable_override: %v", err)
+	}
+	if !reflect.DeepEqual(got, override) {
+		t.Errorf("expected %v, got: %v", override, got)
+	}
+
+	qs, err = queryString(t, AuthOverrideNone())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if qs != "auth_variable_override=null" {
+		t.Errorf("expected auth_variable_override=null, got: %s", qs)
+	}
+
+	// cannot be combined with a user supplied auth
+	idToken := func(q *Query) error {
+		q.Values.Set("auth", "idtoken")
+		return nil
+	}
+	if _, err = queryString(t, idToken, AuthUID("user1")); err == nil {
+		t.Errorf("expected error combining auth_variable_override with non-admin auth")
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	r, err := NewDatabaseRef(
+		URL("https://example.firebaseio.com/"),
+		DefaultHeaders(http.Header{
+			"x-tenant":              {"a"},
+			"X-Cloud-Trace-Context": {"default"},
+		}),
+	)
+	if err != nil {
+		t.Fatalf("could not create database ref: %v", err)
+	}
+
+	req, cancel, err := r.Ref("/child").createRequest(context.Background(), "GET", nil,
+		Header("X-Cloud-Trace-Context", "trace/1"),
+		Header("X-Extra", "1"),
+		Header("X-Extra", "2"),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	cancel()
+
+	exp := http.Header{
+		"X-Tenant":              {"a"},
+		"X-Cloud-Trace-Context": {"trace/1"},
+		"X-Extra":               {"1", "2"},
+	}
+	if !reflect.DeepEqual(req.Header, exp) {
+		t.Errorf("expected %v, got: %v", exp, req.Header)
+	}
+
+	// reserved headers
+	for _, k := range []string{"Authorization", "content-type"} {
+		if _, err := queryString(t, Header(k, "x")); err == nil {
+			t.Errorf("expected error for %s", k)
+		}
+		if _, err := NewDatabaseRef(URL("https://example.firebaseio.com/"), DefaultHeaders(http.Header{k: {"x"}})); err == nil {
+			t.Errorf("expected error for default %s", k)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+// Package otelfirebase provides OpenTelemetry tracing for Firebase database
+// refs.
+//
+// A client span is started for each request attempt made by a database ref,
+// and trace context headers are injected into the request. Watch (and Listen)
+// streams are traced with a single long-lived span, ended when the stream is
+// closed, with a span event for each received event.
+//
+// Example:
+//
+//	db, err := firebase.NewDatabaseRef(
+//	    firebase.GoogleServiceAccountCredentialsFile("creds.json"),
+//	    otelfirebase.Tracing(),
+//	)
+package otelfirebase
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/knq/firebase"
+)
+
+// InstrumentationName is the name of the tracer used by the transport.
+const InstrumentationName = "github.com/knq/firebase/otelfirebase"
+
+// config holds the tracing configuration.
+type config struct {
+	provider    trace.TracerProvider
+	propagators propagation.TextMapPropagator
+}
+
+// Option is a tracing option.
+type Option func(*config)
+
+// TracerProvider is an option that sets the tracer provider used to create
+// spans. Defaults to the global tracer provider.
+func TracerProvider(provider trace.TracerProvider) Option {
+	return func(c *config) {
+		c.provider = provider
+	}
+}
+
+// Propagators is an option that sets the propagators used to inject trace
+// context into requests. Defaults to the global propagators.
+func Propagators(propagators propagation.TextMapPropagator) Option {
+	return func(c *config) {
+		c.propagators = propagators
+	}
+}
+
+// Tracing is a database ref option that traces the requests made with the
+// database ref (see firebase.WrapTransport).
+func Tracing(opts ...Option) firebase.Option {
+	return firebase.WrapTransport(func(rt http.RoundTripper) http.RoundTripper {
+		return NewTransport(rt, opts...)
+	})
+}
+
+// Transport is a http.RoundTripper that traces Firebase requests.
+type Transport struct {
+	base        http.RoundTripper
+	tracer      trace.Tracer
+	propagators propagation.TextMapPropagator
+}
+
+// NewTransport creates a new tracing transport wrapping base. When base is
+// nil, http.DefaultTransport is used.
+func NewTransport(base http.RoundTripper, opts ...Option) *Transport {
+	c := &config{
+		provider:    otel.GetTracerProvider(),
+		propagators: otel.GetTextMapPropagator(),
+	}
+	for _, o := range opts {
+		o(c)
+	}
+
+	return &Transport{
+		base:        base,
+		tracer:      c.provider.Tracer(InstrumentationName, trace.WithInstrumentationVersion(firebase.Version)),
+		propagators: c.propagators,
+	}
+}
+
+// RoundTrip satisfies the http.RoundTripper interface.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	// determine operation
+	op, stream := req.Method, strings.Contains(req.Header.Get("Accept"), "text/event-stream")
+	if stream {
+		op = "WATCH"
+	}
+
+	// start span
+	path := sanitizePath(req.URL.Path)
+	attrs := []attribute.KeyValue{
+		attribute.String("db.system", "firebase"),
+		attribute.String("db.operation", op),
+		attribute.String("http.request.method", req.Method),
+		attribute.String("server.address", req.URL.Hostname()),
+		attribute.String("url.path", path),
+	}
+	if attempt := firebase.RequestAttempt(req.Context()); attempt > 0 {
+		attrs = append(attrs, attribute.Int("firebase.attempt", attempt))
+		if attempt > 1 {
+			attrs = append(attrs, attribute.Int("http.request.resend_count", attempt-1))
+		}
+	}
+	ctxt, span := t.tracer.Start(req.Context(), "firebase "+op+" "+path, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+
+	// inject trace context (into a copy of the request, as a RoundTripper
+	// must not modify the request)
+	req = req.WithContext(ctxt)
+	req.Header = req.Header.Clone()
+	t.propagators.Inject(ctxt, propagation.HeaderCarrier(req.Header))
+
+	res, err := base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.End()
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.Int("http.response.status_code", res.StatusCode))
+	if res.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, res.Status)
+	}
+
+	// end the span when the body is closed, so that the span covers the
+	// lifetime of watch streams
+	if stream && res.StatusCode == http.StatusOK {
+		span.AddEvent("stream opened")
+		res.Body = &streamBody{ReadCloser: res.Body, span: span}
+	} else {
+		res.Body = &spanBody{ReadCloser: res.Body, span: span}
+	}
+
+	return res, nil
+}
+
+// sanitizePath returns the database path of the request path p (ie, without
+// the .json suffix).
+func sanitizePath(p string) string {
+	p = strings.TrimSuffix(p, ".json")
+	if p == "" {
+		return "/"
+	}
+	return p
+}
+
+// spanBody is a response body that ends its span when closed.
+type spanBody struct {
+	io.ReadCloser
+	span trace.Span
+	once sync.Once
+}
+
+// Close satisfies the io.Closer interface.
+func (sb *spanBody) Close() error {
+	err := sb.ReadCloser.Close()
+	sb.once.Do(func() {
+		sb.span.End()
+	})
+	return err
+}
+
+// streamBody is an event stream response body that adds a span event for each
+// event received, and ends its span when closed.
+type streamBody struct {
+	io.ReadCloser
+	span trace.Span
+	once sync.Once
+
+	// line is the incomplete last line read.
+	line []byte
+}
+
+// Read satisfies the io.Reader interface.
+func (sb *streamBody) Read(p []byte) (int, error) {
+	n, err := sb.ReadCloser.Read(p)
+
+	buf := append(sb.line, p[:n]...)
+	for {
+		i := bytes.IndexByte(buf, '\n')
+		if i < 0 {
+			break
+		}
+		if line := bytes.TrimSpace(buf[:i]); bytes.HasPrefix(line, []byte("event:")) {
+			typ := string(bytes.TrimSpace(line[len("event:"):]))
+			sb.span.AddEvent("event", trace.WithAttributes(attribute.String("firebase.event", typ)))
+		}
+		buf = buf[i+1:]
+	}
+	sb.line = append(sb.line[:0], buf...)
+
+	if err != nil && err != io.EOF {
+		sb.span.RecordError(err)
+	}
+
+	return n, err
+}
+
+// Close satisfies the io.Closer interface.
+func (sb *streamBody) Close() error {
+	err := sb.ReadCloser.Close()
+	sb.once.Do(func() {
+		sb.span.AddEvent("stream closed")
+		sb.span.End()
+	})
+	return err
+}
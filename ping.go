@@ -0,0 +1,83 @@
+package firebase
+
+import (
+	"context"
+)
+
+// pingConfig holds the configuration of a ping.
+type pingConfig struct {
+	path      string
+	queryOpts []QueryOption
+}
+
+// PingOption is an option to modify a ping.
+type PingOption func(p *pingConfig) error
+
+// PingPath is a ping option that sets the path probed by the ping, relative to
+// the root of the database ref (ie, a path readable by the credentials when
+// the root is not).
+func PingPath(path string) PingOption {
+	return func(p *pingConfig) error {
+		if err := checkPath(path); err != nil {
+			return err
+		}
+
+		p.path = path
+		return nil
+	}
+}
+
+// PingQueryOptions is a ping option that sets additional query options passed
+// to the ping's request (ie, a Timeout).
+func PingQueryOptions(opts ...QueryOption) PingOption {
+	return func(p *pingConfig) error {
+		p.queryOpts = opts
+		return nil
+	}
+}
+
+// Ping verifies connectivity with the Firebase database of r, and that the
+// credentials of r are valid, by making a shallow request for the probed path
+// (the root of r, by default) with print=silent, such that no data is
+// downloaded even for large databases.
+//
+// Ping returns nil when the probe succeeds, an error matching
+// ErrPermissionDenied (see errors.Is) when the credentials cannot read the
+// probed path, or the transport or server error otherwise. The default timeout
+// of r is applied to the probe.
+func Ping(r *DatabaseRef, opts ...PingOption) error {
+	return PingContext(context.Background(), r, opts...)
+}
+
+// PingContext verifies connectivity with the Firebase database of r, and that
+// the credentials of r are valid, using the provided context.
+func PingContext(ctxt context.Context, r *DatabaseRef, opts ...PingOption) error {
+	var err error
+
+	// apply opts
+	p := &pingConfig{
+		path: "/",
+	}
+	for _, o := range opts {
+		err = o(p)
+		if err != nil {
+			return err
+		}
+	}
+
+	queryOpts := append([]QueryOption{Shallow, PrintSilent}, p.queryOpts...)
+	return GetContext(ctxt, r.Root().Ref(p.path), nil, queryOpts...)
+}
+
+// Ping verifies connectivity with the Firebase database of the database ref,
+// and that the credentials of the database ref are valid.
+func (r *DatabaseRef) Ping(opts ...PingOption) error {
+	return Ping(r, opts...)
+}
+
+// PingContext verifies connectivity with the Firebase database of the
+// database ref, and that the credentials of the database ref are valid, using
+// the provided context.
+func (r *DatabaseRef) PingContext(ctxt context.Context, opts ...PingOption) error {
+	return PingContext(ctxt, r, opts...)
+}
@@ -0,0 +1,45 @@
+package firebase
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		q := req.URL.Query()
+		if exp := "print=silent&shallow=true"; q.Encode() != exp {
+			t.Errorf("expected %s, got: %s", exp, req.URL.RawQuery)
+		}
+		switch req.URL.Path {
+		case "/.json", "/public.json":
+			w.WriteHeader(http.StatusNoContent)
+		case "/slow.json":
+			time.Sleep(200 * time.Millisecond)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"Permission denied"}`))
+		}
+	}))
+	defer srv.Close()
+
+	r := newTestRef(t, srv).Ref("/a/b")
+	if err := r.Ping(); err != nil {
+		t.Errorf("expected no error, got: %v", err)
+	}
+	if err := r.Ping(PingPath("/public")); err != nil {
+		t.Errorf("expected no error, got: %v", err)
+	}
+	if err := r.Ping(PingPath("/private")); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("expected ErrPermissionDenied, got: %v", err)
+	}
+
+	// default timeout
+	r = newTestRef(t, srv, DefaultTimeout(50*time.Millisecond))
+	if err := r.Ping(PingPath("/slow")); err == nil {
+		t.Errorf("expected timeout error")
+	}
+}
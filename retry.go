@@ -0,0 +1,247 @@
+package firebase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultRetryAfter is the default time to wait before retrying a
+	// throttled request when the server does not send a Retry-After header.
+	DefaultRetryAfter = 1 * time.Second
+
+	// DefaultRetryAfterMax is the default maximum time to wait before retrying
+	// a throttled request.
+	DefaultRetryAfterMax = 30 * time.Second
+)
+
+// DefaultRetryPolicy is the default retry policy, retrying transient failures
+// up to 3 times, with exponential backoff between 100ms and 5s.
+var DefaultRetryPolicy = ExponentialBackoff{
+	MaxAttempts: 3,
+	MinBackoff:  100 * time.Millisecond,
+	MaxBackoff:  5 * time.Second,
+}
+
+// RetryPolicy is the interface for retry policies, determining whether or not
+// failed requests made with a database ref are retried (see Retry).
+type RetryPolicy interface {
+	// ShouldRetry returns the time to wait before the next attempt of a
+	// request with method that failed on the specified attempt (starting at
+	// 1), and whether or not the request should be retried.
+	//
+	// The status is the status code of the server's response, or 0 when the
+	// request failed without a response (ie, a connection error), and err is
+	// the request's error.
+	ShouldRetry(attempt int, method string, status int, err error) (time.Duration, bool)
+}
+
+// ExponentialBackoff is a retry policy that retries transient failures (ie,
+// connection errors, and 500, 502, 503 and 504 server errors) with
+// exponential backoff and jitter between attempts.
+type ExponentialBackoff struct {
+	// MaxAttempts is the maximum number of total attempts.
+	MaxAttempts int
+
+	// MinBackoff and MaxBackoff are the minimum and maximum backoff between
+	// attempts.
+	MinBackoff, MaxBackoff time.Duration
+}
+
+// ShouldRetry satisfies the RetryPolicy interface.
+func (eb ExponentialBackoff) ShouldRetry(attempt int, method string, status int, err error) (time.Duration, bool) {
+	if attempt >= eb.MaxAttempts {
+		return 0, false
+	}
+
+	switch status {
+	case 0:
+		// connection error
+		var ue *url.Error
+		if !errors.As(err, &ue) {
+			return 0, false
+		}
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+	default:
+		return 0, false
+	}
+
+	return backoff(eb.MinBackoff, eb.MaxBackoff, attempt), true
+}
+
+// NoRetry is a retry policy that never retries requests.
+type NoRetry struct{}
+
+// ShouldRetry satisfies the RetryPolicy interface.
+func (NoRetry) ShouldRetry(int, string, int, error) (time.Duration, bool) {
+	return 0, false
+}
+
+// backoff returns the exponential backoff (with jitter) between min and max to
+// wait for the attempt.
+func backoff(min, max time.Duration, attempt int) time.Duration {
+	d := min
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+
+	// jitter between [d/2, d]
+	if d > 1 {
+		d = d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+	}
+
+	return d
+}
+
+// retryOptions are the retry options for requests made with a database ref.
+type retryOptions struct {
+	throttleAttempts int
+	retryAfterMax    time.Duration
+
+	policy RetryPolicy
+
+	nonIdempotent bool
+}
+
+// idempotent returns true when requests with method can be retried.
+func (ro retryOptions) idempotent(method string) bool {
+	return method != "POST" || ro.nonIdempotent
+}
+
+// enabled returns true when requests with method may be retried.
+func (ro retryOptions) enabled(method string) bool {
+	if !ro.idempotent(method) {
+		return false
+	}
+	if ro.throttleAttempts > 1 {
+		return true
+	}
+
+	switch p := ro.policy.(type) {
+	case nil, NoRetry:
+		return false
+	case ExponentialBackoff:
+		return p.MaxAttempts > 1
+	}
+	return true
+}
+
+// retry returns the time to wait before retrying a request with method that
+// failed on the specified attempt with the response res (if any) and err, and
+// whether or not the request should be retried.
+func (ro retryOptions) retry(attempt int, method string, res *http.Response, err error) (time.Duration, bool) {
+	if !ro.idempotent(method) {
+		return 0, false
+	}
+
+	// throttled
+	if d, ok := ro.throttled(res, attempt); ok {
+		return d, true
+	}
+
+	if ro.policy == nil {
+		return 0, false
+	}
+
+	var status int
+	if res != nil {
+		status = res.StatusCode
+	}
+
+	return ro.policy.ShouldRetry(attempt, method, status, err)
+}
+
+// throttled returns the time to wait before retrying a request that received
+// the response res on the specified attempt, and whether or not the request
+// was throttled and should be retried.
+func (ro retryOptions) throttled(res *http.Response, attempt int) (time.Duration, bool) {
+	if res == nil || attempt >= ro.throttleAttempts {
+		return 0, false
+	}
+	if res.StatusCode != http.StatusTooManyRequests && res.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+
+	d, ok := parseRetryAfter(res.Header.Get("Retry-After"), time.Now())
+	if !ok {
+		d = DefaultRetryAfter
+	}
+	if d > ro.retryAfterMax {
+		d = ro.retryAfterMax
+	}
+
+	return d, true
+}
+
+// attemptKey is the context key for the attempt number of a request.
+type attemptKey struct{}
+
+// RequestAttempt returns the attempt number (starting at 1) of the request
+// with context ctxt, as made by a database ref, or 0 when ctxt is not the
+// context of a database ref request (ie, for Watch or Listen).
+//
+// RequestAttempt is intended for use by transports (see WrapTransport), such
+// as for tracing or metrics, via the request's Context.
+func RequestAttempt(ctxt context.Context) int {
+	attempt, _ := ctxt.Value(attemptKey{}).(int)
+	return attempt
+}
+
+// retryError wraps err, the last error of a request, with the number of
+// attempts made. When err is an *Error, its fields are retained.
+func retryError(attempts int, err error) error {
+	if attempts < 2 {
+		return err
+	}
+
+	var e Error
+	if x, ok := err.(*Error); ok {
+		e = *x
+		e.Err = fmt.Sprintf("giving up after %d attempts: %s", attempts, x.Err)
+	} else {
+		e.Err = fmt.Sprintf("giving up after %d attempts: %v", attempts, err)
+	}
+	e.err = err
+
+	return &e
+}
+
+// parseRetryAfter parses a Retry-After header value in either the
+// delta-seconds or HTTP-date form, returning the time to wait relative to
+// now.
+func parseRetryAfter(s string, now time.Time) (time.Duration, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, false
+	}
+
+	// delta-seconds
+	if n, err := strconv.Atoi(s); err == nil {
+		if n < 0 {
+			return 0, false
+		}
+		return time.Duration(n) * time.Second, true
+	}
+
+	// http-date
+	t, err := http.ParseTime(s)
+	if err != nil {
+		return 0, false
+	}
+	d := t.Sub(now)
+	if d < 0 {
+		d = 0
+	}
+
+	return d, true
+}
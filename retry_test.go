@@ -0,0 +1,314 @@
+package firebase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2016, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		s  string
+		d  time.Duration
+		ok bool
+	}{
+		{"", 0, false},
+		{"0", 0, true},
+		{"120", 2 * time.Minute, true},
+		{"-1", 0, false},
+		{"Mon, 01 Aug 2016 12:00:30 GMT", 30 * time.Second, true},
+		{"Mon, 01 Aug 2016 11:00:00 GMT", 0, true},
+		{"soon", 0, false},
+	}
+
+	for i, test := range tests {
+		d, ok := parseRetryAfter(test.s, now)
+		if d != test.d || ok != test.ok {
+			t.Errorf("test %d expected %s, %t, got: %s, %t", i, test.d, test.ok, d, ok)
+		}
+	}
+}
+
+// newThrottleServer creates a test server that throttles the first n
+// requests, counting all requests in count.
+func newThrottleServer(n int32, count *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(count, 1) <= n {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"name":"-KXYZ"}`))
+	}))
+}
+
+func TestRetryOnThrottle(t *testing.T) {
+	var count int32
+	srv := newThrottleServer(2, &count)
+	defer srv.Close()
+
+	r := newTestRef(t, srv, RetryOnThrottle(3))
+
+	if err := r.Set(map[string]interface{}{"a": 1}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if n := atomic.LoadInt32(&count); n != 3 {
+		t.Errorf("expected 3 attempts, got: %d", n)
+	}
+
+	// push is not retried
+	atomic.StoreInt32(&count, 0)
+	if _, err := r.Push(1); err == nil {
+		t.Fatalf("expected error")
+	}
+	if n := atomic.LoadInt32(&count); n != 1 {
+		t.Errorf("expected 1 attempt, got: %d", n)
+	}
+
+	// unless opted in
+	atomic.StoreInt32(&count, 0)
+	id, err := r.Ref("", RetryNonIdempotent).Push(1)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if id != "-KXYZ" {
+		t.Errorf("expected -KXYZ, got: %s", id)
+	}
+
+	// attempts exhausted
+	atomic.StoreInt32(&count, 0)
+	var e *Error
+	if err = r.Ref("", RetryOnThrottle(2)).Set(1); !errors.As(err, &e) || e.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected 429 error, got: %v", err)
+	}
+}
+
+func TestRetryOnThrottleContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Retry-After", "600")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	r := newTestRef(t, srv, RetryOnThrottle(5), RetryAfterMax(time.Hour))
+
+	ctxt, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := r.SetContext(ctxt, 1)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected error to wrap context.DeadlineExceeded, got: %v", err)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var count int32
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		buf, _ := ioutil.ReadAll(req.Body)
+		bodies = append(bodies, string(buf))
+		if atomic.AddInt32(&count, 1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write(buf)
+	}))
+	defer srv.Close()
+
+	policy := ExponentialBackoff{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+	r := newTestRef(t, srv, Retry(policy))
+
+	// body is resent for each attempt
+	if err := r.Set(strings.NewReader(`{"a":1}`)); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(bodies) != 3 || bodies[0] != `{"a":1}` || bodies[2] != `{"a":1}` {
+		t.Errorf("expected body to be sent on each attempt, got: %q", bodies)
+	}
+
+	// attempts exhausted
+	atomic.StoreInt32(&count, -10)
+	err := r.Set(1)
+	var e *Error
+	if !errors.As(err, &e) || e.StatusCode != http.StatusBadGateway || !strings.Contains(e.Err, "3 attempts") {
+		t.Errorf("expected 502 error after 3 attempts, got: %v", err)
+	}
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("expected error to be ErrUnavailable, got: %v", err)
+	}
+
+	// connection errors are retried
+	srv.Close()
+	if err = r.Set(1); err == nil || !strings.Contains(err.Error(), "3 attempts") {
+		t.Errorf("expected connection error after 3 attempts, got: %v", err)
+	}
+}
+
+// retryPolicyFunc is a RetryPolicy func.
+type retryPolicyFunc func(int, string, int, error) (time.Duration, bool)
+
+// ShouldRetry satisfies the RetryPolicy interface.
+func (f retryPolicyFunc) ShouldRetry(attempt int, method string, status int, err error) (time.Duration, bool) {
+	return f(attempt, method, status, err)
+}
+
+func TestRetryPolicy(t *testing.T) {
+	var count int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&count, 1) == 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte("null"))
+	}))
+	defer srv.Close()
+
+	// the first attempt fails without a response, the second with a 400
+	var calls []string
+	policy := retryPolicyFunc(func(attempt int, method string, status int, err error) (time.Duration, bool) {
+		calls = append(calls, fmt.Sprintf("%d %s %d %t", attempt, method, status, err != nil))
+		return time.Millisecond, method != "DELETE"
+	})
+	var failed bool
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if !failed {
+			failed = true
+			return nil, errors.New("connection reset")
+		}
+		return http.DefaultTransport.RoundTrip(req)
+	})
+	r := newTestRef(t, srv, Transport(transport), Retry(policy))
+
+	if err := r.Set(1); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if exp := []string{"1 PUT 0 true", "2 PUT 400 true"}; !reflect.DeepEqual(calls, exp) {
+		t.Errorf("expected %v, got: %v", exp, calls)
+	}
+
+	// not retried
+	atomic.StoreInt32(&count, 0)
+	if err := r.Remove(); err == nil || atomic.LoadInt32(&count) != 1 {
+		t.Errorf("expected error after 1 attempt, got: %v after %d", err, atomic.LoadInt32(&count))
+	}
+
+	// no retry
+	atomic.StoreInt32(&count, 0)
+	r = newTestRef(t, srv, Retry(NoRetry{}))
+	if err := r.Set(1); err == nil || atomic.LoadInt32(&count) != 1 {
+		t.Errorf("expected error after 1 attempt, got: %v after %d", err, atomic.LoadInt32(&count))
+	}
+
+	// wait is bounded by the context
+	atomic.StoreInt32(&count, 0)
+	r = newTestRef(t, srv, Retry(retryPolicyFunc(func(int, string, int, error) (time.Duration, bool) {
+		return time.Hour, true
+	})))
+	ctxt, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := r.SetContext(ctxt, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected error to wrap context.DeadlineExceeded, got: %v", err)
+	}
+
+	if _, err := NewDatabaseRef(Retry(nil)); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestRetryNotRetried(t *testing.T) {
+	var count int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&count, 1)
+		if req.Method == "POST" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	r := newTestRef(t, srv, Retry(DefaultRetryPolicy))
+
+	var v interface{}
+	if err := r.Get(&v); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got: %v", err)
+	}
+	if _, err := r.Push(1); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable, got: %v", err)
+	}
+	if n := atomic.LoadInt32(&count); n != 2 {
+		t.Errorf("expected 2 attempts, got: %d", n)
+	}
+}
+
+func TestRequestAttempt(t *testing.T) {
+	var count int32
+	srv := newThrottleServer(2, &count)
+	defer srv.Close()
+
+	var attempts []int
+	wrap := func(rt http.RoundTripper) http.RoundTripper {
+		return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			attempts = append(attempts, RequestAttempt(req.Context()))
+			return http.DefaultTransport.RoundTrip(req)
+		})
+	}
+
+	r := newTestRef(t, srv, RetryOnThrottle(3), WrapTransport(wrap))
+	if err := r.Set(1); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if exp := []int{1, 2, 3}; !reflect.DeepEqual(attempts, exp) {
+		t.Errorf("expected attempts %v, got: %v", exp, attempts)
+	}
+
+	if n := RequestAttempt(context.Background()); n != 0 {
+		t.Errorf("expected 0, got: %d", n)
+	}
+}
+
+func TestRequestIDHeader(t *testing.T) {
+	var ids []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ids = append(ids, req.Header.Get("X-Request-Id"))
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	r := newTestRef(t, srv, RetryOnThrottle(2), RequestIDHeader("x-request-id", func() string { return "abc" }))
+	err := r.Set(1)
+	e, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("expected *Error, got: %T", err)
+	}
+	if e.RequestID != "abc" || !strings.Contains(e.Error(), "(request id abc)") {
+		t.Errorf("expected request id abc, got: %s", e)
+	}
+	if exp := []string{"abc.1", "abc.2"}; !reflect.DeepEqual(ids, exp) {
+		t.Errorf("expected ids %v, got: %v", exp, ids)
+	}
+
+	// default generator
+	ids = nil
+	r = newTestRef(t, srv, RequestIDHeader("X-Request-Id", nil))
+	r.Set(1)
+	if len(ids) != 1 || !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}\.1$`).MatchString(ids[0]) {
+		t.Errorf("expected uuid request id, got: %v", ids)
+	}
+
+	if err := RequestIDHeader("Authorization", nil)(r); err == nil {
+		t.Errorf("expected error")
+	}
+}
@@ -0,0 +1,351 @@
+package firebase
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Rules is a Firebase security rules document (ie, {"rules": {...}}).
+//
+// Numeric values in the rules are retained as json.Number values.
+type Rules struct {
+	m map[string]interface{}
+}
+
+// NewRules creates a new, empty security rules document.
+func NewRules() *Rules {
+	return &Rules{
+		m: map[string]interface{}{
+			"rules": map[string]interface{}{},
+		},
+	}
+}
+
+// Get returns the value at path in the rules (ie, Get("rules", "users",
+// ".read") or Get("rules/users/.read")), or nil if there is no value at path.
+func (r *Rules) Get(path ...string) interface{} {
+	var v interface{} = r.m
+	for _, seg := range rulesPath(path) {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		if v, ok = m[seg]; !ok {
+			return nil
+		}
+	}
+	return v
+}
+
+// Set sets the value at path in the rules (ie, Set("rules/users/.read",
+// true)), creating any intermediate nodes as necessary.
+func (r *Rules) Set(path string, value interface{}) error {
+	segs := rulesPath([]string{path})
+	if len(segs) == 0 {
+		return &Error{Err: "rules path cannot be empty"}
+	}
+
+	if r.m == nil {
+		r.m = make(map[string]interface{})
+	}
+
+	m := r.m
+	for i, seg := range segs[:len(segs)-1] {
+		child, ok := m[seg].(map[string]interface{})
+		if !ok {
+			if _, exists := m[seg]; exists {
+				return &Error{
+					Err: fmt.Sprintf("rules path %s is not an object", "/"+strings.Join(segs[:i+1], "/")),
+				}
+			}
+			child = make(map[string]interface{})
+			m[seg] = child
+		}
+		m = child
+	}
+	m[segs[len(segs)-1]] = value
+
+	return nil
+}
+
+// Delete removes the value at path in the rules (ie, Delete("rules",
+// "users", ".write")).
+func (r *Rules) Delete(path ...string) {
+	segs := rulesPath(path)
+	if len(segs) == 0 {
+		return
+	}
+
+	parent, ok := r.Get(segs[:len(segs)-1]...).(map[string]interface{})
+	if !ok {
+		return
+	}
+	delete(parent, segs[len(segs)-1])
+}
+
+// MarshalJSON satisfies the json.Marshaler interface.
+func (r *Rules) MarshalJSON() ([]byte, error) {
+	if r.m == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(r.m)
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface.
+func (r *Rules) UnmarshalJSON(buf []byte) error {
+	var m map[string]interface{}
+
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+	err := dec.Decode(&m)
+	if err != nil {
+		return err
+	}
+
+	r.m = m
+
+	return nil
+}
+
+// rulesPath splits the path parts into their non-empty segments.
+func rulesPath(parts []string) []string {
+	var segs []string
+	for _, p := range parts {
+		segs = append(segs, splitPath(p)...)
+	}
+	return segs
+}
+
+// GetRules retrieves the security rules for Firebase database ref r.
+//
+// Any comments in the rules are discarded.
+func GetRules(r *DatabaseRef) (*Rules, error) {
+	buf, err := GetRulesJSON(r)
+	if err != nil {
+		return nil, err
+	}
+
+	buf, err = stripComments(buf)
+	if err != nil {
+		return nil, &Error{
+			Err: fmt.Sprintf("could not decode rules: %v", err),
+		}
+	}
+
+	rules := new(Rules)
+	err = json.Unmarshal(buf, rules)
+	if err != nil {
+		return nil, &Error{
+			Err: fmt.Sprintf("could not decode rules: %v", err),
+		}
+	}
+
+	return rules, nil
+}
+
+// GetRules retrieves the security rules for the Firebase database ref.
+func (r *DatabaseRef) GetRules() (*Rules, error) {
+	return GetRules(r)
+}
+
+// stripComments returns a copy of the rules document buf with all // line and
+// /* */ block comments replaced by whitespace, respecting string literals.
+// Newlines within comments are retained, so that line numbers in errors
+// remain accurate.
+//
+// Block comments may be nested (ie, /* a /* b */ c */).
+func stripComments(buf []byte) ([]byte, error) {
+	out := make([]byte, 0, len(buf))
+
+	for i := 0; i < len(buf); i++ {
+		c := buf[i]
+		switch {
+		// string literal
+		case c == '"':
+			out = append(out, c)
+			for i++; i < len(buf); i++ {
+				out = append(out, buf[i])
+				if buf[i] == '\\' && i+1 < len(buf) {
+					i++
+					out = append(out, buf[i])
+					continue
+				}
+				if buf[i] == '"' {
+					break
+				}
+			}
+			if i >= len(buf) {
+				return nil, errors.New("unterminated string literal")
+			}
+
+		// line comment
+		case c == '/' && i+1 < len(buf) && buf[i+1] == '/':
+			for ; i < len(buf) && buf[i] != '\n'; i++ {
+				out = append(out, ' ')
+			}
+			if i < len(buf) {
+				out = append(out, '\n')
+			}
+
+		// block comment
+		case c == '/' && i+1 < len(buf) && buf[i+1] == '*':
+			depth := 0
+			for ; i < len(buf); i++ {
+				switch {
+				case buf[i] == '/' && i+1 < len(buf) && buf[i+1] == '*':
+					depth++
+					out = append(out, ' ', ' ')
+					i++
+				case buf[i] == '*' && i+1 < len(buf) && buf[i+1] == '/':
+					depth--
+					out = append(out, ' ', ' ')
+					i++
+				case buf[i] == '\n':
+					out = append(out, '\n')
+				default:
+					out = append(out, ' ')
+				}
+				if depth == 0 {
+					break
+				}
+			}
+			if depth != 0 {
+				return nil, errors.New("unterminated block comment")
+			}
+
+		default:
+			out = append(out, c)
+		}
+	}
+
+	return out, nil
+}
+
+// rulesConfig is the configuration for setting security rules.
+type rulesConfig struct {
+	validate bool
+}
+
+// RulesOption is an option to modify setting security rules.
+type RulesOption func(rc *rulesConfig) error
+
+// RulesValidate is a rules option that validates the security rules (see
+// ValidateRulesJSON) prior to applying them, refusing to apply the rules when
+// validation fails.
+func RulesValidate(rc *rulesConfig) error {
+	rc.validate = true
+	return nil
+}
+
+// RulesError is a security rules validation error.
+type RulesError struct {
+	// Line and Column are the position of the error in the rules document,
+	// when reported by the server.
+	Line, Column int
+
+	// Message is the error message.
+	Message string
+}
+
+// String satisfies the fmt.Stringer interface.
+func (e RulesError) String() string {
+	switch {
+	case e.Line != 0 && e.Column != 0:
+		return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+	case e.Line != 0:
+		return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+	}
+	return e.Message
+}
+
+// RulesValidationError is the error returned when security rules fail
+// validation by the server.
+type RulesValidationError struct {
+	// Errors are the validation errors.
+	Errors []RulesError
+
+	// err is the server error.
+	err error
+}
+
+// Error satisfies the error interface.
+func (e *RulesValidationError) Error() string {
+	errs := make([]string, len(e.Errors))
+	for i, re := range e.Errors {
+		errs[i] = re.String()
+	}
+	return "firebase: invalid rules: " + strings.Join(errs, "; ")
+}
+
+// Unwrap returns the underlying server error.
+func (e *RulesValidationError) Unwrap() error {
+	return e.err
+}
+
+// rulesErrorRE matches a line of a rules validation error returned by the
+// server (ie, "Line 3: Unknown variable 'foo'.").
+var rulesErrorRE = regexp.MustCompile(`(?i)^line\s+(\d+)(?:,?\s*col(?:umn)?\s+(\d+))?\s*:\s*(.*)$`)
+
+// parseRulesErrors parses the rules validation errors in msg.
+func parseRulesErrors(msg string) []RulesError {
+	var errs []RulesError
+	for _, line := range strings.Split(msg, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		m := rulesErrorRE.FindStringSubmatch(line)
+		if m == nil {
+			errs = append(errs, RulesError{Message: line})
+			continue
+		}
+
+		re := RulesError{Message: m[3]}
+		re.Line, _ = strconv.Atoi(m[1])
+		re.Column, _ = strconv.Atoi(m[2])
+		errs = append(errs, re)
+	}
+	return errs
+}
+
+// dryRunQuery is a query option that causes the server to validate a write
+// without applying it.
+func dryRunQuery(q *Query) error {
+	q.Values.Set("dryRun", "true")
+	return nil
+}
+
+// ValidateRulesJSON validates the JSON-encoded security rules buf for Firebase
+// database ref r using the server's dry run mode, without applying the rules.
+//
+// A *RulesValidationError is returned when the server rejects the rules.
+func ValidateRulesJSON(r *DatabaseRef, buf []byte) error {
+	err := Do(OpTypeSet, r.Ref("/.settings/rules"), buf, nil, dryRunQuery)
+
+	var e *Error
+	if errors.As(err, &e) && e.StatusCode == http.StatusBadRequest {
+		errs := parseRulesErrors(e.Message)
+		if len(errs) == 0 {
+			errs = []RulesError{{Message: e.Err}}
+		}
+		return &RulesValidationError{
+			Errors: errs,
+			err:    err,
+		}
+	}
+
+	return err
+}
+
+// ValidateRulesJSON validates the JSON-encoded security rules buf for the
+// Firebase database ref, without applying the rules.
+func (r *DatabaseRef) ValidateRulesJSON(buf []byte) error {
+	return ValidateRulesJSON(r, buf)
+}
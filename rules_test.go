@@ -0,0 +1,255 @@
+package firebase
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRules(t *testing.T) {
+	const doc = `{"rules":{".read":false,"limit":12345678901234567890,"users":{"$uid":{".validate":"newData.child('age').isNumber()",".write":"$uid === auth.uid"},".indexOn":["age","name"]}}}`
+
+	var stored []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/.settings/rules.json" {
+			t.Errorf("expected /.settings/rules.json, got: %s", req.URL.Path)
+		}
+		switch req.Method {
+		case "GET":
+			w.Write([]byte(doc))
+		case "PUT":
+			stored, _ = ioutil.ReadAll(req.Body)
+			w.Write(stored)
+		}
+	}))
+	defer srv.Close()
+
+	r := newTestRef(t, srv)
+
+	rules, err := r.GetRules()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// round trip
+	if err = r.SetRules(rules); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if string(stored) != doc {
+		t.Errorf("expected %s, got: %s", doc, string(stored))
+	}
+
+	if v := rules.Get("rules", "users/$uid", ".write"); v != "$uid === auth.uid" {
+		t.Errorf("expected .write rule, got: %v", v)
+	}
+	if v, ok := rules.Get("rules/limit").(json.Number); !ok || v.String() != "12345678901234567890" {
+		t.Errorf("expected json.Number, got: %T %v", rules.Get("rules/limit"), rules.Get("rules/limit"))
+	}
+	if v := rules.Get("rules/missing/.read"); v != nil {
+		t.Errorf("expected nil, got: %v", v)
+	}
+
+	// modify
+	if err = rules.Set("rules/users/$uid/.read", true); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err = rules.Set("rules/posts/.read", "auth != null"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err = rules.Set("rules/.read/x", true); err == nil {
+		t.Errorf("expected error setting child of leaf")
+	}
+	rules.Delete("rules", "users", "$uid", ".validate")
+	rules.Delete("rules/limit")
+
+	buf, err := json.Marshal(rules)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	const exp = `{"rules":{".read":false,"posts":{".read":"auth != null"},"users":{"$uid":{".read":true,".write":"$uid === auth.uid"},".indexOn":["age","name"]}}}`
+	if string(buf) != exp {
+		t.Errorf("expected %s, got: %s", exp, string(buf))
+	}
+}
+
+func TestValidateRulesJSON(t *testing.T) {
+	var applied int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		buf, _ := ioutil.ReadAll(req.Body)
+		if req.URL.Query().Get("dryRun") != "true" {
+			applied++
+			w.Write(buf)
+			return
+		}
+		if strings.Contains(string(buf), "foo") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"\nLine 3: Unknown variable 'foo'.\nLine 5, column 10: Expected '}'.\n"}`))
+			return
+		}
+		w.Write(buf)
+	}))
+	defer srv.Close()
+
+	r := newTestRef(t, srv)
+
+	if err := r.ValidateRulesJSON([]byte(`{"rules":{".read":true}}`)); err != nil {
+		t.Errorf("expected no error, got: %v", err)
+	}
+
+	bad := []byte(`{"rules":{".read":"foo"}}`)
+	err := r.ValidateRulesJSON(bad)
+	var e *RulesValidationError
+	if !errors.As(err, &e) {
+		t.Fatalf("expected *RulesValidationError, got: %v", err)
+	}
+	exp := []RulesError{
+		{Line: 3, Message: "Unknown variable 'foo'."},
+		{Line: 5, Column: 10, Message: "Expected '}'."},
+	}
+	if !reflect.DeepEqual(e.Errors, exp) {
+		t.Errorf("expected %v, got: %v", exp, e.Errors)
+	}
+	if !errors.Is(err, ErrBadRequest) {
+		t.Errorf("expected error to wrap ErrBadRequest")
+	}
+
+	// invalid rules are not applied
+	if err = r.SetRulesJSON(bad, RulesValidate); !errors.As(err, &e) {
+		t.Errorf("expected *RulesValidationError, got: %v", err)
+	}
+	if applied != 0 {
+		t.Errorf("expected invalid rules to not be applied")
+	}
+	if err = r.SetRulesJSON([]byte(`{"rules":{".read":true}}`), RulesValidate); err != nil {
+		t.Errorf("expected no error, got: %v", err)
+	}
+	if applied != 1 {
+		t.Errorf("expected valid rules to be applied")
+	}
+}
+
+func TestSetRulesReader(t *testing.T) {
+	const doc = `{
+  "rules": {
+    "z": {".read": true},
+    "a": {".read": false}
+  }
+}`
+
+	var stored []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if ct := req.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("expected application/json, got: %q", ct)
+		}
+		stored, _ = ioutil.ReadAll(req.Body)
+		w.Write(stored)
+	}))
+	defer srv.Close()
+
+	r := newTestRef(t, srv)
+
+	if err := r.SetRulesReader(strings.NewReader(doc)); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if string(stored) != doc {
+		t.Errorf("expected rules to be sent unmodified, got: %s", string(stored))
+	}
+
+	// key order is preserved
+	stored = nil
+	if err := r.SetRulesJSON([]byte(doc)); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if string(stored) != doc {
+		t.Errorf("expected rules to be sent unmodified, got: %s", string(stored))
+	}
+
+	if err := r.SetRulesJSON([]byte(`{"rules":`)); err == nil {
+		t.Errorf("expected error for malformed rules")
+	}
+}
+
+func TestStripComments(t *testing.T) {
+	tests := []struct {
+		s, exp string
+		valid  bool
+	}{
+		{`{"a":1}`, `{"a":1}`, true},
+		{"{\n// comment\n\"a\":1}", "{\n          \n\"a\":1}", true},
+		{`{"a":"http://x"}`, `{"a":"http://x"}`, true},
+		{`{"a":"/* not a comment */"}`, `{"a":"/* not a comment */"}`, true},
+		{`{"a":"\"//"}`, `{"a":"\"//"}`, true},
+		{"{/* a\n/* nested */ b */\"a\":1}", "{    \n                 \"a\":1}", true},
+		{`{"a":1,}`, `{"a":1,}`, false},
+		{"{\"a\":1, // trailing\n}", "{\"a\":1,            \n}", false},
+	}
+
+	for i, test := range tests {
+		buf, err := stripComments([]byte(test.s))
+		if err != nil {
+			t.Errorf("test %d expected no error, got: %v", i, err)
+			continue
+		}
+		if string(buf) != test.exp {
+			t.Errorf("test %d expected %q, got: %q", i, test.exp, string(buf))
+		}
+		if v := json.Valid(buf); v != test.valid {
+			t.Errorf("test %d expected valid %t, got: %t", i, test.valid, v)
+		}
+	}
+
+	for i, s := range []string{`{"a":"x`, `{/* a /* b */`} {
+		if _, err := stripComments([]byte(s)); err == nil {
+			t.Errorf("test %d expected error", i)
+		}
+	}
+}
+
+func TestSetRulesJSONComments(t *testing.T) {
+	const doc = `{
+  // allow reads
+  "rules": {
+    /* users /* nested */ */
+    ".read": "root.child('urls').hasChild('http://x')",
+  }
+}`
+
+	var stored []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case "PUT":
+			stored, _ = ioutil.ReadAll(req.Body)
+		case "GET":
+			w.Write(stored)
+		}
+	}))
+	defer srv.Close()
+
+	r := newTestRef(t, srv)
+
+	// trailing commas are rejected
+	if err := r.SetRulesJSON([]byte(doc)); err == nil {
+		t.Errorf("expected error for trailing comma")
+	}
+
+	fixed := strings.Replace(doc, `')",`, `')"`, 1)
+	if err := r.SetRulesJSON([]byte(fixed)); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if string(stored) != fixed {
+		t.Errorf("expected comments to be preserved, got: %s", string(stored))
+	}
+
+	rules, err := r.GetRules()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if v := rules.Get("rules/.read"); v != "root.child('urls').hasChild('http://x')" {
+		t.Errorf("expected .read rule, got: %v", v)
+	}
+}
@@ -0,0 +1,112 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// serverTimeOffset is the cached server time offset of a database, shared by
+// the refs created from a database ref.
+type serverTimeOffset struct {
+	mu      sync.Mutex
+	offset  time.Duration
+	fetched time.Time
+}
+
+// ServerTimeOffset retrieves the offset of the local clock from the Firebase
+// server's clock (ie, the server's time is time.Now().Add(offset)), as stored
+// at the special .info/serverTimeOffset path of the root of r.
+//
+// When a ttl is passed, an offset retrieved within the ttl (by any ref created
+// from the same database ref) is returned without making a request.
+func ServerTimeOffset(r *DatabaseRef, ttl ...time.Duration) (time.Duration, error) {
+	return ServerTimeOffsetContext(context.Background(), r, ttl...)
+}
+
+// ServerTimeOffsetContext retrieves the offset of the local clock from the
+// Firebase server's clock, using the provided context.
+func ServerTimeOffsetContext(ctxt context.Context, r *DatabaseRef, ttl ...time.Duration) (time.Duration, error) {
+	if len(ttl) > 1 {
+		return 0, &Error{
+			Err: fmt.Sprintf("expected at most one ttl, got: %d", len(ttl)),
+		}
+	}
+
+	r.rw.RLock()
+	sto := r.timeOffset
+	r.rw.RUnlock()
+
+	sto.mu.Lock()
+	defer sto.mu.Unlock()
+
+	// cached
+	if len(ttl) != 0 && !sto.fetched.IsZero() && time.Since(sto.fetched) < ttl[0] {
+		return sto.offset, nil
+	}
+
+	var ms json.Number
+	err := GetContext(ctxt, r.Root().Ref("/.info/serverTimeOffset"), &ms)
+	if err != nil {
+		return 0, err
+	}
+	if ms == "" {
+		return 0, &Error{
+			Err:    "server time offset not available",
+			Method: string(OpTypeGet),
+			Path:   "/.info/serverTimeOffset",
+		}
+	}
+	f, err := ms.Float64()
+	if err != nil {
+		return 0, &Error{
+			Err:    fmt.Sprintf("invalid server time offset %q", ms),
+			Method: string(OpTypeGet),
+			Path:   "/.info/serverTimeOffset",
+		}
+	}
+
+	sto.offset, sto.fetched = time.Duration(f*float64(time.Millisecond)), time.Now()
+	return sto.offset, nil
+}
+
+// ServerNow returns the Firebase server's current time, by applying the
+// server time offset (see ServerTimeOffset) to the local clock.
+func ServerNow(r *DatabaseRef, ttl ...time.Duration) (time.Time, error) {
+	return ServerNowContext(context.Background(), r, ttl...)
+}
+
+// ServerNowContext returns the Firebase server's current time, using the
+// provided context.
+func ServerNowContext(ctxt context.Context, r *DatabaseRef, ttl ...time.Duration) (time.Time, error) {
+	offset, err := ServerTimeOffsetContext(ctxt, r, ttl...)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Now().Add(offset), nil
+}
+
+// ServerTimeOffset retrieves the offset of the local clock from the Firebase
+// server's clock.
+func (r *DatabaseRef) ServerTimeOffset(ttl ...time.Duration) (time.Duration, error) {
+	return ServerTimeOffset(r, ttl...)
+}
+
+// ServerTimeOffsetContext retrieves the offset of the local clock from the
+// Firebase server's clock, using the provided context.
+func (r *DatabaseRef) ServerTimeOffsetContext(ctxt context.Context, ttl ...time.Duration) (time.Duration, error) {
+	return ServerTimeOffsetContext(ctxt, r, ttl...)
+}
+
+// ServerNow returns the Firebase server's current time.
+func (r *DatabaseRef) ServerNow(ttl ...time.Duration) (time.Time, error) {
+	return ServerNow(r, ttl...)
+}
+
+// ServerNowContext returns the Firebase server's current time, using the
+// provided context.
+func (r *DatabaseRef) ServerNowContext(ctxt context.Context, ttl ...time.Duration) (time.Time, error) {
+	return ServerNowContext(ctxt, r, ttl...)
+}
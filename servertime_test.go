@@ -0,0 +1,53 @@
+package firebase
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestServerTimeOffset(t *testing.T) {
+	var n int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&n, 1)
+		if req.URL.Path != "/.info/serverTimeOffset.json" {
+			t.Errorf("expected /.info/serverTimeOffset.json, got: %s", req.URL.Path)
+		}
+		w.Write([]byte(`-1500.5`))
+	}))
+	defer srv.Close()
+
+	// deep ref
+	r := newTestRef(t, srv).Ref("/a/b")
+	offset, err := r.ServerTimeOffset()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if exp := -1500500 * time.Microsecond; offset != exp {
+		t.Errorf("expected %s, got: %s", exp, offset)
+	}
+
+	// cached by refs created from the same ref
+	for i := 0; i < 3; i++ {
+		now, err := r.Parent().ServerNow(time.Minute)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if d := time.Until(now) - offset; d > time.Second || d < -time.Second {
+			t.Errorf("expected server time to be offset by %s, got: %s", offset, d)
+		}
+	}
+	if i := atomic.LoadInt32(&n); i != 1 {
+		t.Errorf("expected 1 request, got: %d", i)
+	}
+
+	// no ttl
+	if _, err := r.ServerTimeOffset(); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if i := atomic.LoadInt32(&n); i != 2 {
+		t.Errorf("expected 2 requests, got: %d", i)
+	}
+}
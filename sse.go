@@ -3,140 +3,383 @@ package firebase
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"golang.org/x/net/context"
 )
 
-const (
-	watchEventPrefix = "event: "
-	watchDataPrefix  = "data: "
-)
-
-// readLine reads a line from a io.Reader, synthesizing errEventType if an
-// error was encountered, or the line is missing the supplied prefix.
+// readEvent reads the next event in the server-sent event wire format from
+// rdr, synthesizing an error event if an error was encountered, if the read
+// event is malformed, or if the event exceeds max bytes (when max is greater
+// than 0).
 //
-// if the prefix is the empty string, then readLine tests for a blank or empty
-// line.
-func readLine(rdr *bufio.Reader, prefix string, errEventType EventType) ([]byte, *Event) {
-	// read event: line
-	line, err := rdr.ReadBytes('\n')
-	if err == io.EOF {
-		return nil, &Event{
-			Type: EventTypeClosed,
-			Data: []byte("connection closed"),
-		}
-	} else if err != nil {
-		return nil, &Event{
-			Type: EventTypeUnknownError,
-			Data: []byte(err.Error()),
-		}
-	}
-
-	// empty line check for empty prefix
-	if len(prefix) == 0 {
-		line = bytes.TrimSpace(line)
-		if len(line) != 0 {
-			return nil, &Event{
-				Type: errEventType,
-				Data: []byte("expected empty line"),
+// The payload of put and patch events is decoded, with the returned event's
+// Path and Data set to the payload's path and data, respectively.
+func readEvent(rdr *bufio.Reader, max int64) *Event {
+	var typ []byte
+	var data [][]byte
+	var size int64
+
+	for {
+		// read line
+		line, err := readLine(rdr, size, max)
+		size += int64(len(line))
+		if err == io.EOF {
+			return &Event{
+				Type: EventTypeClosed,
+				Data: []byte("connection closed"),
+			}
+		} else if err != nil {
+			return &Event{
+				Type: EventTypeUnknownError,
+				Data: []byte(err.Error()),
 			}
 		}
-		return line, nil
+		line = bytes.TrimRight(line, "\r\n")
+
+		// blank line dispatches the event
+		if len(line) == 0 {
+			if typ == nil && data == nil {
+				continue
+			}
+			break
+		}
+
+		// skip comments
+		if line[0] == ':' {
+			continue
+		}
+
+		// split field and value
+		field, val := line, []byte{}
+		if i := bytes.IndexByte(line, ':'); i != -1 {
+			field, val = line[:i], bytes.TrimPrefix(line[i+1:], []byte(" "))
+		}
+
+		switch string(field) {
+		case "event":
+			typ = val
+		case "data":
+			data = append(data, val)
+		}
+	}
+
+	if len(typ) == 0 {
+		return &Event{
+			Type: EventTypeMalformedEventError,
+			Data: []byte("missing event type"),
+		}
+	}
+
+	raw := bytes.Join(data, []byte("\n"))
+	e := &Event{
+		Type: EventType(typ),
+		Data: raw,
+		Raw:  raw,
 	}
 
-	// check line has event prefix
-	if !bytes.HasPrefix(line, []byte(prefix)) {
-		return nil, &Event{
-			Type: errEventType,
-			Data: []byte("missing prefix"),
+	// decode payload
+	if e.Type == EventTypePut || e.Type == EventTypePatch {
+		var v struct {
+			Path string          `json:"path"`
+			Data json.RawMessage `json:"data"`
+		}
+		err := json.Unmarshal(e.Data, &v)
+		if err != nil {
+			return &Event{
+				Type: EventTypeMalformedDataError,
+				Data: []byte(err.Error()),
+			}
 		}
+		e.Path, e.Data = v.Path, []byte(v.Data)
 	}
 
-	// trim space
-	return bytes.TrimSpace(line[len([]byte(prefix)):]), nil
+	return e
+}
+
+// readLine reads the next line from rdr, returning a *ResponseTooLargeError
+// when read (the size of the event already read) plus the line's length
+// exceeds max (when max is greater than 0).
+func readLine(rdr *bufio.Reader, read, max int64) ([]byte, error) {
+	if max <= 0 {
+		return rdr.ReadBytes('\n')
+	}
+
+	var line []byte
+	for {
+		buf, err := rdr.ReadSlice('\n')
+		if n := read + int64(len(line)+len(buf)); n > max {
+			return nil, &ResponseTooLargeError{Limit: max, Read: n}
+		}
+		line = append(line, buf...)
+		if err != bufio.ErrBufferFull {
+			return line, err
+		}
+	}
 }
 
 // Watch watches a Firebase ref for events, emitting encountered events on the
 // returned channel. Watch ends when the passed context is done, when the
-// remote connection is closed, or when an error is encountered while reading
-// data.
+// remote connection is closed, when the server cancels the watch or revokes
+// the auth token, or when an error is encountered while reading events from
+// the server. The terminal event (see Event.Err) is emitted prior to the
+// channel being closed.
+//
+// Redirects issued by Firebase for the streaming connection (ie, 307
+// Temporary Redirect) are followed.
+//
+// Query options (ie, OrderBy, LimitToLast, etc) passed to Watch are applied to
+// the streaming connection in the same manner as with Get, and can be used to
+// watch a window of the ref's children. As children enter and leave the
+// window, Firebase sends put events with the child's path relative to the ref
+// (ie, "/<key>"), with null data for children leaving the window.
+//
+// When the WatchReconnect option has been set on the ref, the connection is
+// transparently re-established (with backoff) when it is closed or canceled
+// by the server, and a fresh put event for the root of the ref is emitted by
+// Firebase on the new connection. The returned channel is then closed only
+// when the passed context is done, or when the maximum number of reconnect
+// attempts is exceeded, after emitting the event that caused the last
+// reconnect.
 //
 // NOTE: the Log option will not work with Watch/Listen.
-// events from the server.
 func Watch(r *DatabaseRef, ctxt context.Context, opts ...QueryOption) (<-chan *Event, error) {
+	r.rw.RLock()
+	wo, bufLen := r.watchOpts, r.watchBufLen
+	r.rw.RUnlock()
+
+	if !wo.reconnect {
+		return watch(r, ctxt, opts...)
+	}
+
+	// connect
+	connCtxt, connCancel := context.WithCancel(ctxt)
+	evs, err := watch(r, connCtxt, opts...)
+	if err != nil {
+		connCancel()
+		return nil, err
+	}
+
+	events := make(chan *Event, bufLen)
+	go func() {
+		defer close(events)
+
+		var attempt int
+		for {
+			// forward events until the connection is done
+			var reason *Event
+			for e := range evs {
+				if isReconnectEvent(e.Type) {
+					reason = e
+					break
+				}
+
+				// connection is healthy
+				attempt = 0
+
+				select {
+				case events <- e:
+				case <-ctxt.Done():
+					connCancel()
+					return
+				}
+			}
+			connCancel()
+
+			// reconnect
+			for {
+				if ctxt.Err() != nil || reason == nil {
+					return
+				}
+
+				// give up
+				attempt++
+				if wo.maxAttempts > 0 && attempt > wo.maxAttempts {
+					select {
+					case events <- reason:
+					case <-ctxt.Done():
+					}
+					return
+				}
+
+				if wo.onReconnect != nil {
+					wo.onReconnect(attempt, reason)
+				}
+
+				// wait
+				select {
+				case <-time.After(wo.backoff(attempt)):
+				case <-ctxt.Done():
+					return
+				}
+
+				connCtxt, connCancel = context.WithCancel(ctxt)
+				evs, err = watch(r, connCtxt, opts...)
+				if err == nil {
+					break
+				}
+				connCancel()
+
+				reason = &Event{
+					Type: EventTypeUnknownError,
+					Data: []byte(err.Error()),
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// isReconnectEvent returns true when typ indicates that the watch connection
+// is done (ie, is a terminal event), and should be re-established when
+// reconnecting.
+func isReconnectEvent(typ EventType) bool {
+	switch typ {
+	case EventTypeCancel, EventTypeAuthRevoked, EventTypeClosed, EventTypeIdleTimeout,
+		EventTypeUnknownError, EventTypeMalformedEventError, EventTypeMalformedDataError:
+		return true
+	}
+	return false
+}
+
+// watch creates a single streaming connection to the Firebase ref, emitting
+// encountered events on the returned channel.
+func watch(r *DatabaseRef, ctxt context.Context, opts ...QueryOption) (<-chan *Event, error) {
 	var err error
 
-	// get client and request
-	client, req, err := r.clientAndRequest("GET", nil, opts...)
+	r.rw.RLock()
+	wo, bufLen, rl, maxRes := r.watchOpts, r.watchBufLen, r.reqLog, r.maxResponseBytes
+	r.rw.RUnlock()
+	dc := r.decoder(opts...)
+
+	// connection context, used to forcibly close idle connections
+	connCtxt, connCancel := context.WithCancel(ctxt)
+
+	// get client and request (the default timeout should not apply to
+	// long-lived watches)
+	opts = append([]QueryOption{Timeout(0)}, opts...)
+	client, req, cancel, err := r.clientAndRequest(connCtxt, "GET", nil, opts...)
 	if err != nil {
+		connCancel()
 		return nil, err
 	}
+	if c, ok := client.(*http.Client); ok {
+		c.Timeout = 0
+	}
 
 	// set request headers
 	req.Header.Add("Accept", "text/event-stream")
 
 	// execute
+	start := time.Now()
 	res, err := client.Do(req)
 	if err != nil {
+		rl.request(req, nil, nil, start, err)
+		cancel()
+		connCancel()
+
+		// pass through errors from the token source
+		var e *Error
+		if errors.As(err, &e) {
+			return nil, e
+		}
+
+		err = redactError(err)
 		return nil, &Error{
 			Err: fmt.Sprintf("could not execute request: %v", err),
+			err: err,
 		}
 	}
 
 	// check server error
 	err = checkServerError(res)
+	rl.request(req, res, nil, start, err)
 	if err != nil {
+		res.Body.Close()
+		cancel()
+		connCancel()
 		return nil, err
 	}
 
-	events := make(chan *Event, r.watchBufLen)
+	// close the connection when no events are received within the idle
+	// timeout
+	var idle int32
+	var idleTimer *time.Timer
+	if wo.idleTimeout > 0 {
+		idleTimer = time.AfterFunc(wo.idleTimeout, func() {
+			atomic.StoreInt32(&idle, 1)
+			connCancel()
+		})
+	}
+
+	events := make(chan *Event, bufLen)
 	go func() {
+		defer connCancel()
+		defer cancel()
 		defer res.Body.Close()
+		defer close(events)
+		if idleTimer != nil {
+			defer idleTimer.Stop()
+		}
+
+		// log the end of the watch
+		var count int
+		reason := "context done"
+		if rl.logf != nil {
+			defer func() {
+				rl.logf("firebase: watch %s %s ended after %d events: %s", req.Method, redact(req.URL.String()), count, reason)
+			}()
+		}
 
 		// create reader
 		rdr := bufio.NewReader(res.Body)
 
-		var errEvent *Event
-		var typ, data []byte
-
 		for {
-			select {
-			default:
-				// read line "event: <event>"
-				typ, errEvent = readLine(rdr, watchEventPrefix, EventTypeMalformedEventError)
-				if errEvent != nil {
-					events <- errEvent
-					close(events)
-					return
-				}
+			e := readEvent(rdr, maxRes)
+			e.dec = dc
 
-				// read line "data: <data>"
-				data, errEvent = readLine(rdr, watchDataPrefix, EventTypeMalformedDataError)
-				if errEvent != nil {
-					events <- errEvent
-					close(events)
-					return
-				}
+			// context finished (aborts the read)
+			if ctxt.Err() != nil {
+				return
+			}
 
-				// emit event
-				events <- &Event{
-					Type: EventType(typ),
-					Data: data,
+			// idle timeout
+			if atomic.LoadInt32(&idle) != 0 {
+				e = &Event{
+					Type: EventTypeIdleTimeout,
+					Data: []byte(fmt.Sprintf("no events received within %s", wo.idleTimeout)),
 				}
+			} else if idleTimer != nil {
+				idleTimer.Reset(wo.idleTimeout)
+			}
 
-				// consume empty line
-				_, errEvent = readLine(rdr, "", EventTypeUnknownError)
-				if errEvent != nil {
-					events <- errEvent
-					close(events)
-					return
-				}
+			// suppress keep alives
+			if e.Type == EventTypeKeepAlive && wo.suppressKeepAlive {
+				continue
+			}
 
-			// context finished
+			// emit event
+			count++
+			select {
+			case events <- e:
 			case <-ctxt.Done():
-				close(events)
+				return
+			}
+
+			// stop on terminal events
+			if isReconnectEvent(e.Type) {
+				reason = string(e.Type)
+				if len(e.Data) != 0 {
+					reason += ": " + string(e.Data)
+				}
 				return
 			}
 		}
@@ -153,9 +396,12 @@ func Watch(r *DatabaseRef, ctxt context.Context, opts ...QueryOption) (<-chan *E
 // continue to reattempt connecting to the Firebase ref.
 //
 // NOTE: the Log option will not work with Watch/Listen.
-// events from the server.
 func Listen(r *DatabaseRef, ctxt context.Context, eventTypes []EventType, opts ...QueryOption) <-chan *Event {
-	events := make(chan *Event, r.watchBufLen)
+	r.rw.RLock()
+	bufLen := r.watchBufLen
+	r.rw.RUnlock()
+
+	events := make(chan *Event, bufLen)
 
 	go func() {
 		for {
@@ -192,3 +438,99 @@ func Listen(r *DatabaseRef, ctxt context.Context, eventTypes []EventType, opts .
 
 	return events
 }
+
+const (
+	// DefaultWatchMinBackoff is the default minimum wait before reconnecting a
+	// watch.
+	DefaultWatchMinBackoff = 500 * time.Millisecond
+
+	// DefaultWatchMaxBackoff is the default maximum wait before reconnecting a
+	// watch.
+	DefaultWatchMaxBackoff = 30 * time.Second
+)
+
+// watchOptions are the reconnect options for watches made with a database
+// ref.
+type watchOptions struct {
+	suppressKeepAlive bool
+	idleTimeout       time.Duration
+
+	reconnect   bool
+	maxAttempts int
+
+	minBackoff, maxBackoff time.Duration
+
+	onReconnect func(int, *Event)
+}
+
+// backoff returns the exponential backoff (with jitter) to wait for the
+// reconnect attempt.
+func (wo watchOptions) backoff(attempt int) time.Duration {
+	return backoff(wo.minBackoff, wo.maxBackoff, attempt)
+}
+
+// RefEvent is a Firebase server side event emitted from WatchMulti, along with
+// the database ref it originated from.
+type RefEvent struct {
+	Ref *DatabaseRef
+	*Event
+}
+
+// WatchMulti watches multiple Firebase refs for events, emitting encountered
+// events from all refs on the returned channel.
+//
+// Each ref is watched independently (see Watch), reconnecting as per the
+// ref's options. When the watch for a ref ends (including when the initial
+// connection fails, such as when permission is denied), a terminal event for
+// the ref is emitted and the remaining refs continue to be watched. The
+// returned channel is closed when the watches for all refs have ended, or
+// when the passed context is done.
+func WatchMulti(refs []*DatabaseRef, ctxt context.Context, opts ...QueryOption) (<-chan *RefEvent, error) {
+	if len(refs) == 0 {
+		return nil, errors.New("no refs to watch")
+	}
+
+	refs[0].rw.RLock()
+	bufLen := refs[0].watchBufLen
+	refs[0].rw.RUnlock()
+
+	events := make(chan *RefEvent, bufLen)
+
+	var wg sync.WaitGroup
+	for _, r := range refs {
+		wg.Add(1)
+		go func(r *DatabaseRef) {
+			defer wg.Done()
+
+			evs, err := Watch(r, ctxt, opts...)
+			if err != nil {
+				select {
+				case events <- &RefEvent{
+					Ref: r,
+					Event: &Event{
+						Type: EventTypeUnknownError,
+						Data: []byte(err.Error()),
+					},
+				}:
+				case <-ctxt.Done():
+				}
+				return
+			}
+
+			for e := range evs {
+				select {
+				case events <- &RefEvent{Ref: r, Event: e}:
+				case <-ctxt.Done():
+					return
+				}
+			}
+		}(r)
+	}
+
+	go func() {
+		wg.Wait()
+		close(events)
+	}()
+
+	return events, nil
+}
@@ -0,0 +1,362 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newStreamServer creates a test server that writes the server-sent events in
+// stream, and then closes the connection.
+func newStreamServer(t *testing.T, stream string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if a := req.Header.Get("Accept"); a != "text/event-stream" {
+			t.Errorf("expected Accept: text/event-stream, got: %q", a)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(stream))
+	}))
+}
+
+func TestWatch(t *testing.T) {
+	srv := newStreamServer(t, ""+
+		"event: put\n"+
+		"data: {\"path\":\"/\",\"data\":{\"a\":1}}\n"+
+		"\n"+
+		": a comment\n"+
+		"event: patch\r\n"+
+		"data: {\"path\":\"/b\",\r\n"+
+		"data: \"data\":{\"c\":true}}\r\n"+
+		"\r\n"+
+		"event: keep-alive\n"+
+		"data: null\n"+
+		"\n",
+	)
+	defer srv.Close()
+
+	// redirect to the stream server
+	redir := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Redirect(w, req, srv.URL+req.URL.Path, http.StatusTemporaryRedirect)
+	}))
+	defer redir.Close()
+
+	ctxt, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	evs, err := newTestRef(t, redir).Watch(ctxt)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	exp := []Event{
+		{Type: EventTypePut, Path: "/", Data: []byte(`{"a":1}`), Raw: []byte(`{"path":"/","data":{"a":1}}`)},
+		{Type: EventTypePatch, Path: "/b", Data: []byte(`{"c":true}`), Raw: []byte("{\"path\":\"/b\",\n\"data\":{\"c\":true}}")},
+		{Type: EventTypeKeepAlive, Data: []byte(`null`), Raw: []byte(`null`)},
+		{Type: EventTypeClosed, Data: []byte(`connection closed`)},
+	}
+
+	var i int
+	for e := range evs {
+		if i >= len(exp) {
+			t.Fatalf("unexpected event: %s", e)
+		}
+		if e.Type != exp[i].Type || e.Path != exp[i].Path || string(e.Data) != string(exp[i].Data) {
+			t.Errorf("event %d expected %s, got: %s", i, exp[i], e)
+		}
+		if string(e.Raw) != string(exp[i].Raw) || (exp[i].Raw == nil) != (e.Raw == nil) {
+			t.Errorf("event %d expected raw %q, got: %q", i, exp[i].Raw, e.Raw)
+		}
+		i++
+	}
+	if i != len(exp) {
+		t.Errorf("expected %d events, got: %d", len(exp), i)
+	}
+}
+
+func TestWatchMalformed(t *testing.T) {
+	tests := []struct {
+		stream string
+		typ    EventType
+	}{
+		{"data: {}\n\n", EventTypeMalformedEventError},
+		{"event: put\ndata: not json\n\n", EventTypeMalformedDataError},
+	}
+
+	for i, test := range tests {
+		srv := newStreamServer(t, test.stream)
+
+		evs, err := newTestRef(t, srv).Watch(context.Background())
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+
+		var last *Event
+		for e := range evs {
+			last = e
+		}
+		srv.Close()
+
+		if last == nil || last.Type != test.typ {
+			t.Errorf("test %d expected %s, got: %v", i, test.typ, last)
+		}
+	}
+}
+
+func TestWatchReconnect(t *testing.T) {
+	// stream on the first 3 connections, and then error
+	var conns int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n := atomic.AddInt32(&conns, 1)
+		if n > 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintf(w, "event: put\ndata: {\"path\":\"/\",\"data\":%d}\n\n", n)
+	}))
+	defer srv.Close()
+
+	var reasons []string
+	r := newTestRef(t, srv,
+		WatchReconnect(2),
+		WatchReconnectBackoff(time.Millisecond, 5*time.Millisecond),
+		WatchOnReconnect(func(attempt int, reason *Event) {
+			reasons = append(reasons, fmt.Sprintf("%d %s", attempt, reason.Type))
+		}),
+	)
+
+	evs, err := r.Watch(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	var data []string
+	var last *Event
+	for e := range evs {
+		if e.Type == EventTypePut {
+			data = append(data, string(e.Data))
+		}
+		last = e
+	}
+
+	if strings.Join(data, ",") != "1,2,3" {
+		t.Errorf("expected a put from each connection, got: %v", data)
+	}
+
+	exp := "1 closed,1 closed,1 closed,2 unknown_error"
+	if s := strings.Join(reasons, ","); s != exp {
+		t.Errorf("expected reconnects %s, got: %s", exp, s)
+	}
+
+	if last == nil || last.Type != EventTypeUnknownError {
+		t.Errorf("expected last event to be %s, got: %v", EventTypeUnknownError, last)
+	}
+}
+
+func TestWatchReconnectCanceled(t *testing.T) {
+	var conns int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&conns, 1)
+		w.Write([]byte("event: put\ndata: {\"path\":\"/\",\"data\":null}\n\n"))
+		w.(http.Flusher).Flush()
+		<-req.Context().Done()
+	}))
+	defer srv.Close()
+
+	r := newTestRef(t, srv, WatchReconnect(0), WatchReconnectBackoff(time.Millisecond, time.Millisecond))
+
+	ctxt, cancel := context.WithCancel(context.Background())
+	evs, err := r.Watch(ctxt)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	<-evs
+	cancel()
+	for range evs {
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	if n := atomic.LoadInt32(&conns); n != 1 {
+		t.Errorf("expected 1 connection, got: %d", n)
+	}
+}
+
+func TestWatchEvents(t *testing.T) {
+	srv := newStreamServer(t, ""+
+		"event: put\ndata: {\"path\":\"/users/abc\",\"data\":{\"name\":\"john\",\"age\":21}}\n\n"+
+		"event: keep-alive\ndata: null\n\n"+
+		"event: cancel\ndata: null\n\n"+
+		"event: put\ndata: {\"path\":\"/\",\"data\":null}\n\n",
+	)
+	defer srv.Close()
+
+	evs, err := newTestRef(t, srv, WatchSuppressKeepAlive).Watch(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// put
+	e := <-evs
+	if e.Type != EventTypePut || e.Path != "/users/abc" || e.Err() != nil {
+		t.Fatalf("expected put, got: %s", e)
+	}
+	var v struct {
+		Name string      `json:"name"`
+		Age  json.Number `json:"age"`
+	}
+	if err = e.Decode(&v); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if v.Name != "john" || v.Age != "21" {
+		t.Errorf("expected john 21, got: %s %s", v.Name, v.Age)
+	}
+	var n int
+	var typeErr *json.UnmarshalTypeError
+	if err = e.Decode(&n); !errors.As(err, &typeErr) {
+		t.Errorf("expected json.UnmarshalTypeError cause, got: %v", err)
+	}
+
+	// keep-alive is suppressed, and cancel is terminal
+	e = <-evs
+	if e.Type != EventTypeCancel || e.Err() == nil {
+		t.Errorf("expected cancel with error, got: %s", e)
+	}
+	if e, ok := <-evs; ok {
+		t.Errorf("expected channel to be closed, got: %s", e)
+	}
+}
+
+func TestWatchQueryOptions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if q := req.URL.RawQuery; q != `limitToLast=2&orderBy=%22%24key%22` {
+			t.Errorf("expected limitToLast and orderBy, got: %s", q)
+		}
+		w.Write([]byte("" +
+			"event: put\ndata: {\"path\":\"/\",\"data\":{\"b\":2,\"c\":3}}\n\n" +
+			"event: put\ndata: {\"path\":\"/b\",\"data\":null}\n\n" +
+			"event: put\ndata: {\"path\":\"/d\",\"data\":4}\n\n" +
+			"event: patch\ndata: {\"path\":\"/d/e\",\"data\":{\"f\":5}}\n\n",
+		))
+	}))
+	defer srv.Close()
+
+	r := newTestRef(t, srv).Ref("/items")
+
+	// range options require orderBy
+	if _, err := r.Watch(context.Background(), LimitToLast(2)); err == nil {
+		t.Errorf("expected error")
+	}
+
+	evs, err := r.Watch(context.Background(), OrderBy("$key"), LimitToLast(2))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	exp := []string{
+		`put /: {"b":2,"c":3}`,
+		`put /b: null`,
+		`put /d: 4`,
+		`patch /d/e: {"f":5}`,
+		`closed: connection closed`,
+	}
+	var i int
+	for e := range evs {
+		if i < len(exp) && e.String() != exp[i] {
+			t.Errorf("event %d expected %s, got: %s", i, exp[i], e)
+		}
+		i++
+	}
+	if i != len(exp) {
+		t.Errorf("expected %d events, got: %d", len(exp), i)
+	}
+}
+
+func TestWatchIdleTimeout(t *testing.T) {
+	var conns int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// keep alive on the first connection, and then stall
+		if atomic.AddInt32(&conns, 1) == 1 {
+			w.Write([]byte("event: keep-alive\ndata: null\n\n"))
+		}
+		w.(http.Flusher).Flush()
+		<-req.Context().Done()
+	}))
+	defer srv.Close()
+
+	var reasons []EventType
+	r := newTestRef(t, srv,
+		WatchIdleTimeout(50*time.Millisecond),
+		WatchReconnect(1),
+		WatchReconnectBackoff(time.Millisecond, time.Millisecond),
+		WatchOnReconnect(func(_ int, reason *Event) {
+			reasons = append(reasons, reason.Type)
+		}),
+	)
+
+	evs, err := r.Watch(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	var last *Event
+	for e := range evs {
+		last = e
+	}
+
+	if last == nil || last.Type != EventTypeIdleTimeout {
+		t.Errorf("expected last event to be %s, got: %v", EventTypeIdleTimeout, last)
+	}
+	if len(reasons) == 0 || reasons[0] != EventTypeIdleTimeout {
+		t.Errorf("expected reconnect reason %s, got: %v", EventTypeIdleTimeout, reasons)
+	}
+	if len(reasons) != 1 {
+		t.Errorf("expected 1 reconnect, got: %d", len(reasons))
+	}
+	if n := atomic.LoadInt32(&conns); n != 2 {
+		t.Errorf("expected 2 connections, got: %d", n)
+	}
+}
+
+func TestWatchMulti(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/a.json", "/b.json":
+			fmt.Fprintf(w, "event: put\ndata: {\"path\":\"/\",\"data\":%q}\n\n", req.URL.Path)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"Permission denied"}`))
+		}
+	}))
+	defer srv.Close()
+
+	r := newTestRef(t, srv)
+	refs := []*DatabaseRef{r.Ref("/a"), r.Ref("/b"), r.Ref("/denied")}
+
+	evs, err := WatchMulti(refs, context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	got := make(map[string][]string)
+	for e := range evs {
+		got[e.Ref.URL().Path] = append(got[e.Ref.URL().Path], string(e.Type))
+	}
+
+	exp := map[string]string{
+		"/a":      "put,closed",
+		"/b":      "put,closed",
+		"/denied": "unknown_error",
+	}
+	for path, s := range exp {
+		if g := strings.Join(got[path], ","); g != s {
+			t.Errorf("%s expected %s, got: %s", path, s, g)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package firebase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Subscribe watches the Firebase ref, keeping the decoded value of target in
+// sync with the values stored at the ref, calling onChange with the path of
+// the changed data (relative to the ref) after each change is applied to
+// target. target must be a non-nil pointer (ie, to a struct or a map).
+//
+// Put events replace the data at the event's path, and patch events merge the
+// event's keys into the data at the event's path, with null data removing the
+// data at the path. After each event, the complete data is decoded into a new
+// zero value, which is assigned to target. Nodes with mostly sequential integer
+// keys are decoded from arrays, as Firebase returns them. When the data cannot
+// be decoded, target is left unchanged and the error is returned.
+//
+// onChange is called synchronously on the calling goroutine, and target
+// should only be accessed from within onChange while Subscribe is running.
+// Subscribe blocks until the passed context is done (returning nil) or the
+// watch ends (returning the error of the terminal event).
+func Subscribe(r *DatabaseRef, ctxt context.Context, target interface{}, onChange func(path string), opts ...QueryOption) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return errors.New("subscribe target must be a non-nil pointer")
+	}
+
+	evs, err := Watch(r, ctxt, opts...)
+	if err != nil {
+		return err
+	}
+	dc := r.decoder(opts...)
+
+	var tree interface{}
+	for e := range evs {
+		if e.Type != EventTypePut && e.Type != EventTypePatch {
+			if err := e.Err(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// decode data (retaining the precision of numbers, as the tree is
+		// re-encoded)
+		var d interface{}
+		err = decoder{}.decode(e.Data, &d)
+		if err != nil {
+			return err
+		}
+
+		// apply
+		segs := splitPath(e.Path)
+		switch e.Type {
+		case EventTypePut:
+			tree = setPath(tree, segs, d)
+
+		case EventTypePatch:
+			m, ok := d.(map[string]interface{})
+			if !ok {
+				return &Error{
+					Err: fmt.Sprintf("invalid patch data at %s", e.Path),
+				}
+			}
+			for k, val := range m {
+				tree = setPath(tree, append(segs[:len(segs):len(segs)], splitPath(k)...), val)
+			}
+		}
+
+		// re-decode target
+		err = decodeTree(tree, v, dc)
+		if err != nil {
+			return err
+		}
+
+		if onChange != nil {
+			onChange(e.Path)
+		}
+	}
+
+	return nil
+}
+
+// splitPath splits a Firebase path into its non-empty segments.
+func splitPath(path string) []string {
+	var segs []string
+	for _, s := range strings.Split(path, "/") {
+		if s != "" {
+			segs = append(segs, s)
+		}
+	}
+	return segs
+}
+
+// setPath sets the value at the path segs in tree, returning the modified
+// tree. A nil val removes the value at the path, and any maps left empty by
+// the removal (as Firebase does not store empty nodes).
+func setPath(tree interface{}, segs []string, val interface{}) interface{} {
+	if len(segs) == 0 {
+		return val
+	}
+
+	var m map[string]interface{}
+	switch t := tree.(type) {
+	case map[string]interface{}:
+		m = t
+	case []interface{}:
+		// nodes with sequential integer keys are returned as arrays
+		m = make(map[string]interface{}, len(t))
+		for i, v := range t {
+			if v != nil {
+				m[strconv.Itoa(i)] = v
+			}
+		}
+	default:
+		m = make(map[string]interface{})
+	}
+
+	child := setPath(m[segs[0]], segs[1:], val)
+	if child == nil {
+		delete(m, segs[0])
+	} else {
+		m[segs[0]] = child
+	}
+
+	if len(m) == 0 {
+		return nil
+	}
+	return toArray(m)
+}
+
+// toArray returns m as an array when its keys are mostly sequential integers
+// (ie, more than half of the keys between 0 and the largest key are present),
+// as Firebase does, or m otherwise.
+func toArray(m map[string]interface{}) interface{} {
+	max := -1
+	for k := range m {
+		i, err := strconv.Atoi(k)
+		if err != nil || i < 0 || strconv.Itoa(i) != k {
+			return m
+		}
+		if i > max {
+			max = i
+		}
+	}
+	if len(m)*2 <= max+1 {
+		return m
+	}
+
+	a := make([]interface{}, max+1)
+	for k, v := range m {
+		i, _ := strconv.Atoi(k)
+		a[i] = v
+	}
+	return a
+}
+
+// decodeTree decodes tree with dc into a new zero value of the type pointed to
+// by v, setting the value pointed to by v only when decoding succeeds.
+func decodeTree(tree interface{}, v reflect.Value, dc decoder) error {
+	buf, err := json.Marshal(tree)
+	if err != nil {
+		return &Error{
+			Err: fmt.Sprintf("could not marshal json: %v", err),
+			err: err,
+		}
+	}
+
+	nv := reflect.New(v.Elem().Type())
+	err = dc.decodeFrom(bytes.NewReader(buf), nv.Interface())
+	if err != nil {
+		return &Error{
+			Err: fmt.Sprintf("could not unmarshal json: %v", err),
+			err: err,
+		}
+	}
+	v.Elem().Set(nv.Elem())
+
+	return nil
+}
+
+// Subscribe watches the Firebase database ref, keeping the decoded value of
+// target in sync with the values stored at the database ref.
+func (r *DatabaseRef) Subscribe(ctxt context.Context, target interface{}, onChange func(path string), opts ...QueryOption) error {
+	return Subscribe(r, ctxt, target, onChange, opts...)
+}
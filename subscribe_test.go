@@ -0,0 +1,119 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSubscribe(t *testing.T) {
+	srv := newStreamServer(t, ""+
+		"event: put\ndata: {\"path\":\"/\",\"data\":{\"abc\":{\"name\":\"john\",\"age\":21},\"def\":{\"name\":\"jane\"}}}\n\n"+
+		"event: put\ndata: {\"path\":\"/abc/name\",\"data\":\"jon\"}\n\n"+
+		"event: patch\ndata: {\"path\":\"/def\",\"data\":{\"age\":30,\"name\":null}}\n\n"+
+		"event: keep-alive\ndata: null\n\n"+
+		"event: put\ndata: {\"path\":\"/abc\",\"data\":null}\n\n"+
+		"event: patch\ndata: {\"path\":\"/\",\"data\":{\"ghi/name\":\"bob\"}}\n\n",
+	)
+	defer srv.Close()
+
+	type person struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+
+	exp := []string{
+		`/ {"abc":{"name":"john","age":21},"def":{"name":"jane","age":0}}`,
+		`/abc/name {"abc":{"name":"jon","age":21},"def":{"name":"jane","age":0}}`,
+		`/def {"abc":{"name":"jon","age":21},"def":{"name":"","age":30}}`,
+		`/abc {"def":{"name":"","age":30}}`,
+		`/ {"def":{"name":"","age":30},"ghi":{"name":"bob","age":0}}`,
+	}
+
+	var i int
+	var people map[string]person
+	err := newTestRef(t, srv).Subscribe(context.Background(), &people, func(path string) {
+		buf, _ := json.Marshal(people)
+		if s := path + " " + string(buf); i < len(exp) && s != exp[i] {
+			t.Errorf("change %d expected %s, got: %s", i, exp[i], s)
+		}
+		i++
+	})
+	if err == nil || !strings.Contains(err.Error(), "closed") {
+		t.Errorf("expected closed error, got: %v", err)
+	}
+	if i != len(exp) {
+		t.Errorf("expected %d changes, got: %d", len(exp), i)
+	}
+}
+
+func TestSubscribeArray(t *testing.T) {
+	srv := newStreamServer(t, ""+
+		"event: put\ndata: {\"path\":\"/\",\"data\":[\"a\",\"b\",\"c\"]}\n\n"+
+		"event: put\ndata: {\"path\":\"/1\",\"data\":\"x\"}\n\n"+
+		"event: put\ndata: {\"path\":\"/\",\"data\":[null,\"y\"]}\n\n"+
+		"event: patch\ndata: {\"path\":\"/\",\"data\":{\"2\":\"z\"}}\n\n",
+	)
+	defer srv.Close()
+
+	exp := []string{
+		`/ ["a","b","c"]`,
+		`/1 ["a","x","c"]`,
+		`/ [null,"y"]`,
+		`/ [null,"y","z"]`,
+	}
+
+	var i int
+	var m interface{}
+	err := newTestRef(t, srv).Subscribe(context.Background(), &m, func(path string) {
+		buf, _ := json.Marshal(m)
+		if s := path + " " + string(buf); i < len(exp) && s != exp[i] {
+			t.Errorf("change %d expected %s, got: %s", i, exp[i], s)
+		}
+		i++
+	})
+	if err == nil || !strings.Contains(err.Error(), "closed") {
+		t.Errorf("expected closed error, got: %v", err)
+	}
+	if i != len(exp) {
+		t.Errorf("expected %d changes, got: %d", len(exp), i)
+	}
+}
+
+func TestSubscribeSlice(t *testing.T) {
+	srv := newStreamServer(t, ""+
+		"event: put\ndata: {\"path\":\"/\",\"data\":[\"a\",\"b\"]}\n\n"+
+		"event: put\ndata: {\"path\":\"/1\",\"data\":\"c\"}\n\n"+
+		"event: patch\ndata: {\"path\":\"/\",\"data\":{\"2\":\"d\"}}\n\n"+
+		"event: put\ndata: {\"path\":\"/name\",\"data\":\"x\"}\n\n",
+	)
+	defer srv.Close()
+
+	exp := []string{
+		`/ ["a","b"]`,
+		`/1 ["a","c"]`,
+		`/ ["a","c","d"]`,
+	}
+
+	var i int
+	var v []string
+	err := newTestRef(t, srv).Subscribe(context.Background(), &v, func(path string) {
+		buf, _ := json.Marshal(v)
+		if s := path + " " + string(buf); i < len(exp) && s != exp[i] {
+			t.Errorf("change %d expected %s, got: %s", i, exp[i], s)
+		}
+		i++
+	})
+	if err == nil || !strings.Contains(err.Error(), "could not unmarshal json") {
+		t.Errorf("expected unmarshal error, got: %v", err)
+	}
+	if i != len(exp) {
+		t.Errorf("expected %d changes, got: %d", len(exp), i)
+	}
+
+	// target is left unchanged by the failed event
+	if s := strings.Join(v, ","); s != "a,c,d" {
+		t.Errorf("expected a,c,d, got: %s", s)
+	}
+}
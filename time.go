@@ -1,7 +1,14 @@
 package firebase
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -17,9 +24,17 @@ const (
 // When this type has a zero value, and is serialized to Firebase, Firebase
 // will store the current time in milliseconds since the Unix epoch. When the
 // value is unserialized from Firebase, then the stored time (ie, milliseconds
-// since the Unix epoch) will be returned.
+// since the Unix epoch) will be returned, in UTC.
+//
+// ServerTimestamp can be used directly as a field type in structs passed to
+// Set, Push and Update, or as a value in a map (see ServerValueTimestamp).
 type ServerTimestamp time.Time
 
+// ServerValueTimestamp is the zero ServerTimestamp, for use as a value with
+// Set, Push and Update, causing Firebase to store the server's current time
+// (ie, map[string]interface{}{"updated": firebase.ServerValueTimestamp}).
+var ServerValueTimestamp = ServerTimestamp{}
+
 // MarshalJSON satisfies the json.Marshaler interface.
 func (st ServerTimestamp) MarshalJSON() ([]byte, error) {
 	t := time.Time(st)
@@ -29,29 +44,17 @@ func (st ServerTimestamp) MarshalJSON() ([]byte, error) {
 		return []byte(serverTimestampValue), nil
 	}
 
-	return []byte(strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)), nil
+	return encodeMillis(t), nil
 }
 
 // UnmarshalJSON satisfies the json.Unmarshaler interface.
 func (st *ServerTimestamp) UnmarshalJSON(buf []byte) error {
-	// special firebase value
-	v := string(buf)
-	switch v {
-	case serverTimestampValue:
-		*st = ServerTimestamp(time.Now())
-		return nil
-
-	case "null":
-		*st = ServerTimestamp{}
-		return nil
-	}
-
-	i, err := strconv.ParseInt(v, 10, 64)
+	t, err := decodeMillis(buf)
 	if err != nil {
 		return err
 	}
 
-	*st = ServerTimestamp(time.Unix(0, i*int64(time.Millisecond)))
+	*st = ServerTimestamp(t)
 	return nil
 }
 
@@ -69,30 +72,32 @@ func (st ServerTimestamp) String() string {
 // compatible with Firebase server timestamps.
 //
 // The Firebase representation of time is a JSON Number of milliseconds since
-// the Unix epoch.
+// the Unix epoch. A zero Time is serialized as null (see EpochTime for a type
+// serializing the zero value as 0).
+//
+// When unserialized, integer, floating point, and quoted milliseconds are
+// accepted, as well as the server timestamp placeholder ({".sv":"timestamp"},
+// decoded as the current time). Unserialized times are in UTC.
 type Time time.Time
 
 // MarshalJSON satisfies the json.Marshaler interface.
 func (t Time) MarshalJSON() ([]byte, error) {
 	z := time.Time(t)
+	if z.IsZero() {
+		return []byte("null"), nil
+	}
 
-	return []byte(strconv.FormatInt(z.UnixNano()/int64(time.Millisecond), 10)), nil
+	return encodeMillis(z), nil
 }
 
 // UnmarshalJSON satisfies the json.Unmarshaler interface.
 func (t *Time) UnmarshalJSON(buf []byte) error {
-	v := string(buf)
-	if v == "null" {
-		*t = Time{}
-		return nil
-	}
-
-	i, err := strconv.ParseInt(v, 10, 64)
+	z, err := decodeMillis(buf)
 	if err != nil {
 		return err
 	}
 
-	*t = Time(time.Unix(0, i*int64(time.Millisecond)))
+	*t = Time(z)
 	return nil
 }
 
@@ -106,12 +111,200 @@ func (t Time) String() string {
 	return time.Time(t).String()
 }
 
+// EpochTime is a Time that is serialized as 0 (ie, the Unix epoch), instead of
+// null, when it has a zero value.
+type EpochTime time.Time
+
+// MarshalJSON satisfies the json.Marshaler interface.
+func (t EpochTime) MarshalJSON() ([]byte, error) {
+	z := time.Time(t)
+	if z.IsZero() {
+		return []byte("0"), nil
+	}
+
+	return encodeMillis(z), nil
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface.
+func (t *EpochTime) UnmarshalJSON(buf []byte) error {
+	z, err := decodeMillis(buf)
+	if err != nil {
+		return err
+	}
+
+	*t = EpochTime(z)
+	return nil
+}
+
+// Time returns the EpochTime as time.Time.
+func (t EpochTime) Time() time.Time {
+	return time.Time(t)
+}
+
+// String satisfies the stringer interface.
+func (t EpochTime) String() string {
+	return time.Time(t).String()
+}
+
+// encodeMillis encodes t as a JSON Number of milliseconds since the Unix
+// epoch.
+func encodeMillis(t time.Time) []byte {
+	return []byte(strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10))
+}
+
+// decodeMillis decodes the JSON-encoded milliseconds since the Unix epoch in
+// buf, accepting integer, floating point, and quoted values, as well as the
+// server timestamp placeholder. null is decoded as the zero time.
+func decodeMillis(buf []byte) (time.Time, error) {
+	v := bytes.TrimSpace(buf)
+	switch {
+	case len(v) == 0:
+		return time.Time{}, &Error{Err: "invalid timestamp: empty value"}
+
+	case string(v) == "null":
+		return time.Time{}, nil
+
+	// special firebase value
+	case v[0] == '{':
+		var sv struct {
+			Value string `json:".sv"`
+		}
+		if err := json.Unmarshal(v, &sv); err != nil || sv.Value != "timestamp" {
+			return time.Time{}, &Error{Err: fmt.Sprintf("invalid timestamp: %s", v)}
+		}
+		return time.Now().UTC(), nil
+
+	case v[0] == '"':
+		var s string
+		if err := json.Unmarshal(v, &s); err != nil {
+			return time.Time{}, &Error{Err: fmt.Sprintf("invalid timestamp: %s", v)}
+		}
+		v = []byte(strings.TrimSpace(s))
+	}
+
+	ms, err := strconv.ParseInt(string(v), 10, 64)
+	if err != nil {
+		f, ferr := strconv.ParseFloat(string(v), 64)
+		if ferr != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return time.Time{}, &Error{Err: fmt.Sprintf("invalid timestamp: %s", v)}
+		}
+		ms = int64(math.Round(f))
+	}
+
+	return time.Unix(0, ms*int64(time.Millisecond)).UTC(), nil
+}
+
 // Error is a general Firebase error.
+//
+// Errors returned for a request have the Method and Path of the request set,
+// and errors returned by the Firebase server additionally have the StatusCode
+// and Message set.
 type Error struct {
 	Err string `json:"error"`
+
+	// StatusCode is the HTTP status code of the server response.
+	StatusCode int `json:"-"`
+
+	// Message is the error message returned by the server (ie, the "error"
+	// field of the response body).
+	Message string `json:"-"`
+
+	// Method is the HTTP method of the request.
+	Method string `json:"-"`
+
+	// Path is the database path of the request.
+	Path string `json:"-"`
+
+	// RequestID is the ID of the request, when sent (see RequestIDHeader).
+	RequestID string `json:"-"`
+
+	// err is the underlying error that caused the Error, if any.
+	err error
 }
 
 // Error satisfies the error interface.
 func (e *Error) Error() string {
-	return "firebase: " + e.Err
+	s := "firebase: "
+	if e.Method != "" {
+		s += e.Method + " " + e.Path + ": "
+	}
+	if e.StatusCode != 0 {
+		s += strconv.Itoa(e.StatusCode) + " "
+	}
+	s += e.Err
+	if e.RequestID != "" {
+		s += " (request id " + e.RequestID + ")"
+	}
+	return s
+}
+
+// Unwrap returns the underlying error that caused the Error, if any.
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// Is returns true when target is the sentinel error (ie, ErrNotFound)
+// corresponding to the Error's status code, for use with errors.Is.
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrPermissionDenied:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrPreconditionFailed:
+		return e.StatusCode == http.StatusPreconditionFailed || e == ErrETagMismatch
+	case ErrUnavailable:
+		return e.StatusCode >= 500 && e.StatusCode <= 599
+	}
+	return false
+}
+
+// ErrorMethod returns the HTTP method of the request that caused err, if err
+// is or wraps an *Error.
+func ErrorMethod(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Method
+	}
+	return ""
+}
+
+// ErrorPath returns the database path of the request that caused err, if err
+// is or wraps an *Error.
+func ErrorPath(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Path
+	}
+	return ""
 }
+
+// Sentinel errors for server errors, for use with errors.Is.
+var (
+	// ErrBadRequest is the error for a bad request (400) server error.
+	ErrBadRequest = &Error{Err: "bad request"}
+
+	// ErrPermissionDenied is the error for an unauthorized (401) or forbidden
+	// (403) server error.
+	ErrPermissionDenied = &Error{Err: "permission denied"}
+
+	// ErrNotFound is the error for a not found (404) server error.
+	ErrNotFound = &Error{Err: "not found"}
+
+	// ErrPreconditionFailed is the error for a precondition failed (412)
+	// server error (ie, an ETag mismatch).
+	ErrPreconditionFailed = &Error{Err: "precondition failed"}
+
+	// ErrUnavailable is the error for a 5xx server error.
+	ErrUnavailable = &Error{Err: "unavailable"}
+)
+
+// ErrLeafNode is the error returned when an operation expecting a node with
+// children encounters a leaf node (ie, a primitive value).
+var ErrLeafNode = &Error{Err: "node is a leaf"}
+
+// ErrNodeNotExists is the error returned by GetStrict when no value is stored
+// at the database ref (ie, the server returned null).
+var ErrNodeNotExists = &Error{Err: "node does not exist"}
@@ -0,0 +1,129 @@
+package firebase
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServerTimestamp(t *testing.T) {
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "GET" {
+			w.Write([]byte(`{"name":"a","created":1500000000123}`))
+			return
+		}
+		buf, _ := ioutil.ReadAll(req.Body)
+		body = string(buf)
+		w.Write(buf)
+	}))
+	defer srv.Close()
+
+	type item struct {
+		Name    string          `json:"name"`
+		Created ServerTimestamp `json:"created"`
+	}
+
+	r := newTestRef(t, srv)
+
+	// write
+	tests := []struct {
+		v   interface{}
+		exp string
+	}{
+		{item{Name: "a"}, `{"name":"a","created":{".sv":"timestamp"}}`},
+		{map[string]interface{}{"created": ServerValueTimestamp}, `{"created":{".sv":"timestamp"}}`},
+	}
+	for i, test := range tests {
+		if err := r.Set(test.v); err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		if strings.TrimSpace(body) != test.exp {
+			t.Errorf("test %d expected %s, got: %s", i, test.exp, body)
+		}
+	}
+
+	// read back
+	var v item
+	if err := r.Get(&v); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	exp := time.Date(2017, time.July, 14, 2, 40, 0, 123*int(time.Millisecond), time.UTC)
+	if tm := v.Created.Time(); !tm.Equal(exp) || tm.Location() != time.UTC {
+		t.Errorf("expected %s, got: %s", exp, tm)
+	}
+}
+
+func TestTime(t *testing.T) {
+	exp := time.Date(2017, time.July, 14, 2, 40, 0, 123*int(time.Millisecond), time.UTC)
+
+	// marshal
+	marshalTests := []struct {
+		v   interface{}
+		exp string
+	}{
+		{Time(exp), `1500000000123`},
+		{Time{}, `null`},
+		{EpochTime(exp), `1500000000123`},
+		{EpochTime{}, `0`},
+	}
+	for i, test := range marshalTests {
+		buf, err := json.Marshal(test.v)
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		if string(buf) != test.exp {
+			t.Errorf("test %d expected %s, got: %s", i, test.exp, string(buf))
+		}
+	}
+
+	// unmarshal
+	unmarshalTests := []struct {
+		s   string
+		exp time.Time
+		err bool
+	}{
+		{`1500000000123`, exp, false},
+		{`1500000000123.0`, exp, false},
+		{`1.500000000123e12`, exp, false},
+		{`"1500000000123"`, exp, false},
+		{`null`, time.Time{}, false},
+		{`0`, time.Unix(0, 0).UTC(), false},
+		{`"abc"`, time.Time{}, true},
+		{`{".sv":"other"}`, time.Time{}, true},
+		{`true`, time.Time{}, true},
+	}
+	for i, test := range unmarshalTests {
+		var v struct {
+			T Time      `json:"t"`
+			E EpochTime `json:"e"`
+		}
+		err := json.Unmarshal([]byte(`{"t":`+test.s+`,"e":`+test.s+`}`), &v)
+		switch {
+		case test.err && err == nil:
+			t.Errorf("test %d expected error", i)
+		case !test.err && err != nil:
+			t.Errorf("test %d expected no error, got: %v", i, err)
+		case !test.err:
+			if tm := v.T.Time(); !tm.Equal(test.exp) || (!tm.IsZero() && tm.Location() != time.UTC) {
+				t.Errorf("test %d expected %s, got: %s", i, test.exp, tm)
+			}
+			if tm := v.E.Time(); !tm.Equal(test.exp) {
+				t.Errorf("test %d expected %s, got: %s", i, test.exp, tm)
+			}
+		}
+	}
+
+	// server timestamp echo
+	var v Time
+	if err := json.Unmarshal([]byte(` { ".sv" : "timestamp" } `), &v); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if d := time.Since(v.Time()); d < 0 || d > time.Minute {
+		t.Errorf("expected current time, got: %s", v)
+	}
+}
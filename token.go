@@ -0,0 +1,114 @@
+package firebase
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	// DefaultTokenRefreshSkew is the default time before a token's expiry
+	// that the token will be refreshed.
+	DefaultTokenRefreshSkew = 1 * time.Minute
+)
+
+// refreshTokenSource is a oauth2.TokenSource that caches the token retrieved
+// from an underlying source, refreshing it prior to its expiration.
+//
+// Only one refresh is in flight at any time. While a refresh is in flight,
+// other callers are returned the cached token if it has not yet expired,
+// otherwise they wait for the refresh to complete.
+type refreshTokenSource struct {
+	mu sync.Mutex
+
+	source oauth2.TokenSource
+	skew   time.Duration
+
+	tok *oauth2.Token
+	err error
+
+	// done is non-nil while a refresh is in flight, and is closed when the
+	// refresh completes.
+	done chan struct{}
+}
+
+// newRefreshTokenSource creates a refreshing token source for source, that
+// refreshes tokens skew prior to their expiration.
+func newRefreshTokenSource(source oauth2.TokenSource, skew time.Duration) *refreshTokenSource {
+	return &refreshTokenSource{
+		source: source,
+		skew:   skew,
+	}
+}
+
+// NewTokenSource creates a caching OAuth2 token source for source, that
+// refreshes tokens skew prior to their expiration.
+//
+// The returned token source is safe for concurrent use, and can be shared
+// between multiple database refs (see the TokenSource option), with only one
+// refresh in flight at any time.
+func NewTokenSource(source oauth2.TokenSource, skew time.Duration) oauth2.TokenSource {
+	return newRefreshTokenSource(source, skew)
+}
+
+// SetSkew sets the time prior to a token's expiry that the token will be
+// refreshed.
+func (ts *refreshTokenSource) SetSkew(skew time.Duration) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.skew = skew
+}
+
+// Token satisfies the oauth2.TokenSource interface.
+func (ts *refreshTokenSource) Token() (*oauth2.Token, error) {
+	now := time.Now()
+
+	ts.mu.Lock()
+	tok := ts.tok
+
+	// cached token is fresh
+	if tok != nil && (tok.Expiry.IsZero() || now.Add(ts.skew).Before(tok.Expiry)) {
+		ts.mu.Unlock()
+		return tok, nil
+	}
+
+	// refresh in flight
+	if done := ts.done; done != nil {
+		// cached token has not yet expired
+		if tok != nil && now.Before(tok.Expiry) {
+			ts.mu.Unlock()
+			return tok, nil
+		}
+
+		// wait for refresh
+		ts.mu.Unlock()
+		<-done
+
+		ts.mu.Lock()
+		defer ts.mu.Unlock()
+		if ts.err != nil {
+			return nil, ts.err
+		}
+		return ts.tok, nil
+	}
+
+	// refresh
+	done := make(chan struct{})
+	ts.done = done
+	ts.mu.Unlock()
+
+	tok, err := ts.source.Token()
+
+	ts.mu.Lock()
+	if err == nil {
+		ts.tok = tok
+	}
+	ts.err = err
+	ts.done = nil
+	close(done)
+	ts.mu.Unlock()
+
+	return tok, err
+}
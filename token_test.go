@@ -0,0 +1,157 @@
+package firebase
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// countTokenSource is a oauth2.TokenSource that counts the number of tokens
+// retrieved, returning tokens expiring after expiry.
+type countTokenSource struct {
+	count  int32
+	expiry time.Duration
+	delay  time.Duration
+	err    error
+}
+
+// Token satisfies the oauth2.TokenSource interface.
+func (ts *countTokenSource) Token() (*oauth2.Token, error) {
+	n := atomic.AddInt32(&ts.count, 1)
+	time.Sleep(ts.delay)
+	if ts.err != nil {
+		return nil, ts.err
+	}
+	return &oauth2.Token{
+		AccessToken: string(rune('a' + n - 1)),
+		Expiry:      time.Now().Add(ts.expiry),
+	}, nil
+}
+
+func TestRefreshTokenSource(t *testing.T) {
+	src := &countTokenSource{expiry: time.Hour}
+	ts := newRefreshTokenSource(src, time.Minute)
+
+	// cached
+	for i := 0; i < 3; i++ {
+		tok, err := ts.Token()
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if tok.AccessToken != "a" {
+			t.Errorf("expected token a, got: %s", tok.AccessToken)
+		}
+	}
+
+	// within skew of expiry
+	ts.SetSkew(2 * time.Hour)
+	tok, err := ts.Token()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if tok.AccessToken != "b" {
+		t.Errorf("expected token b, got: %s", tok.AccessToken)
+	}
+	if n := atomic.LoadInt32(&src.count); n != 2 {
+		t.Errorf("expected 2 retrieved tokens, got: %d", n)
+	}
+}
+
+func TestRefreshTokenSourceConcurrent(t *testing.T) {
+	src := &countTokenSource{expiry: 30 * time.Second, delay: 20 * time.Millisecond}
+	ts := newRefreshTokenSource(src, time.Minute)
+
+	// initial token is already within skew
+	if _, err := ts.Token(); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := ts.Token(); err != nil {
+				t.Errorf("expected no error, got: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&src.count); n > 3 {
+		t.Errorf("expected refreshes to be single flight, got: %d retrieved tokens", n)
+	}
+}
+
+func TestRefreshTokenSourceError(t *testing.T) {
+	src := &countTokenSource{expiry: 30 * time.Second}
+	ts := newRefreshTokenSource(src, time.Minute)
+
+	if _, err := ts.Token(); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// refresh fails, and is returned to the caller triggering the refresh
+	src.err, src.delay = errors.New("refresh failed"), 50*time.Millisecond
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if _, err := ts.Token(); err != src.err {
+			t.Errorf("expected refresh error, got: %v", err)
+		}
+	}()
+
+	// cached token is still valid for other callers
+	time.Sleep(10 * time.Millisecond)
+	tok, err := ts.Token()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if tok.AccessToken != "a" {
+		t.Errorf("expected token a, got: %s", tok.AccessToken)
+	}
+	wg.Wait()
+}
+
+func TestSharedTokenSource(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if a := req.Header.Get("Authorization"); a != "Bearer a" {
+			t.Errorf("expected Bearer a, got: %q", a)
+		}
+		w.Write([]byte(`null`))
+	}))
+	defer srv.Close()
+
+	src := &countTokenSource{expiry: time.Hour, delay: 20 * time.Millisecond}
+	ts := NewTokenSource(src, time.Minute)
+
+	var refs []*DatabaseRef
+	for i := 0; i < 5; i++ {
+		refs = append(refs, newTestRef(t, srv, TokenSource(ts)))
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(r *DatabaseRef) {
+			defer wg.Done()
+			var v interface{}
+			if err := r.Get(&v); err != nil {
+				t.Errorf("expected no error, got: %v", err)
+			}
+		}(refs[i%len(refs)])
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&src.count); n != 1 {
+		t.Errorf("expected exactly 1 retrieved token, got: %d", n)
+	}
+}
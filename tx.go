@@ -0,0 +1,154 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultTxMaxAttempts is the default maximum number of attempts made by
+	// Transaction before giving up.
+	DefaultTxMaxAttempts = 25
+)
+
+// ErrAbort is the error that can be returned by a transaction func to stop a
+// Transaction without writing any values.
+var ErrAbort = errors.New("transaction aborted")
+
+// txConfig holds the configuration of a transaction.
+type txConfig struct {
+	maxAttempts int
+	backoff     time.Duration
+	queryOpts   []QueryOption
+}
+
+// TxOption is an option to modify a transaction.
+type TxOption func(tx *txConfig) error
+
+// TxMaxAttempts is a transaction option that sets the maximum number of
+// attempts made before the transaction gives up.
+func TxMaxAttempts(n int) TxOption {
+	return func(tx *txConfig) error {
+		if n < 1 {
+			return fmt.Errorf("transaction max attempts must be greater than 0, got: %d", n)
+		}
+
+		tx.maxAttempts = n
+		return nil
+	}
+}
+
+// TxBackoff is a transaction option that sets the time to wait between
+// attempts after a conflict.
+func TxBackoff(d time.Duration) TxOption {
+	return func(tx *txConfig) error {
+		if d < 0 {
+			return errors.New("transaction backoff cannot be negative")
+		}
+
+		tx.backoff = d
+		return nil
+	}
+}
+
+// TxQueryOptions is a transaction option that sets the query options passed
+// to each request made by the transaction.
+func TxQueryOptions(opts ...QueryOption) TxOption {
+	return func(tx *txConfig) error {
+		tx.queryOpts = opts
+		return nil
+	}
+}
+
+// Transaction atomically modifies the values stored at Firebase database ref
+// r, by retrieving the current JSON-encoded value of r, passing it to fn, and
+// storing the value returned by fn only if r was not modified in the
+// meantime.
+//
+// When r was modified, fn is called again with the value that was current at
+// the time of the failed write, until the write succeeds or the maximum
+// number of attempts is reached. As such, fn may be called multiple times and
+// should not have side effects.
+//
+// If fn returns ErrAbort, the transaction is stopped and nil is returned. Any
+// other error returned from fn stops the transaction and is returned.
+func Transaction(r *DatabaseRef, fn func(current json.RawMessage) (interface{}, error), opts ...TxOption) error {
+	return TransactionContext(context.Background(), r, fn, opts...)
+}
+
+// TransactionContext atomically modifies the values stored at Firebase
+// database ref r, in the same manner as Transaction, using the provided
+// context.
+func TransactionContext(ctxt context.Context, r *DatabaseRef, fn func(current json.RawMessage) (interface{}, error), opts ...TxOption) error {
+	var err error
+
+	// apply opts
+	tx := &txConfig{
+		maxAttempts: DefaultTxMaxAttempts,
+	}
+	for _, o := range opts {
+		err = o(tx)
+		if err != nil {
+			return err
+		}
+	}
+
+	// retrieve current value
+	var current json.RawMessage
+	etag, err := GetWithETagContext(ctxt, r, &current, tx.queryOpts...)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < tx.maxAttempts; i++ {
+		// wait before retrying
+		if i != 0 && tx.backoff != 0 {
+			select {
+			case <-time.After(tx.backoff):
+			case <-ctxt.Done():
+				return &Error{
+					Err: fmt.Sprintf("transaction canceled: %v", ctxt.Err()),
+					err: ctxt.Err(),
+				}
+			}
+		}
+
+		// modify
+		v, err := fn(current)
+		if errors.Is(err, ErrAbort) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		// write
+		_, err = SetIfUnchangedContext(ctxt, r, etag, v, tx.queryOpts...)
+		var e *ETagMismatchError
+		if !errors.As(err, &e) {
+			return err
+		}
+
+		// conflict, use the current value from the response
+		etag, current = e.ETag, e.Value
+	}
+
+	return &Error{
+		Err: fmt.Sprintf("transaction failed after %d attempts", tx.maxAttempts),
+		err: ErrETagMismatch,
+	}
+}
+
+// Transaction atomically modifies the values stored at the Firebase database
+// ref using fn.
+func (r *DatabaseRef) Transaction(fn func(current json.RawMessage) (interface{}, error), opts ...TxOption) error {
+	return Transaction(r, fn, opts...)
+}
+
+// TransactionContext atomically modifies the values stored at the Firebase
+// database ref using fn, using the provided context.
+func (r *DatabaseRef) TransactionContext(ctxt context.Context, fn func(current json.RawMessage) (interface{}, error), opts ...TxOption) error {
+	return TransactionContext(ctxt, r, fn, opts...)
+}
@@ -0,0 +1,112 @@
+package firebase_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/knq/firebase"
+	"github.com/knq/firebase/firebasetest"
+)
+
+// newCounterRef creates a database ref for the value stored at /n on the fake
+// server srv, that modifies the stored value conflicts times (prior to a
+// write) to simulate concurrent writes, returning a func counting the GETs
+// made with the ref.
+func newCounterRef(srv *firebasetest.Server, conflicts int) (*firebase.DatabaseRef, func() int) {
+	var mu sync.Mutex
+	base := srv.Client().Transport
+	d := firebasetest.NewRecordingDoer(&http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			if req.Method == "PUT" && conflicts > 0 {
+				conflicts--
+				var n int
+				json.Unmarshal(srv.Data("/n"), &n)
+				srv.SetData("/n", n+10)
+			}
+			mu.Unlock()
+			return base.RoundTrip(req)
+		}),
+	})
+
+	return srv.Ref(firebase.HTTPDoer(d)).Ref("/n"), func() int {
+		var gets int
+		for _, req := range d.Requests() {
+			if req.Method == "GET" {
+				gets++
+			}
+		}
+		return gets
+	}
+}
+
+// increment is a transaction func that increments the current value.
+func increment(current json.RawMessage) (interface{}, error) {
+	var n int
+	if err := json.Unmarshal(current, &n); err != nil {
+		return nil, err
+	}
+	return n + 1, nil
+}
+
+func TestTransaction(t *testing.T) {
+	srv := firebasetest.NewServer()
+	defer srv.Close()
+
+	if err := srv.SetData("/n", 0); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	r, gets := newCounterRef(srv, 2)
+	if err := r.Transaction(increment); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if v := string(srv.Data("/n")); v != "21" {
+		t.Errorf("expected 21, got: %s", v)
+	}
+
+	if g := gets(); g != 1 {
+		t.Errorf("expected 1 GET, got: %d", g)
+	}
+}
+
+func TestTransactionMaxAttempts(t *testing.T) {
+	srv := firebasetest.NewServer()
+	defer srv.Close()
+
+	if err := srv.SetData("/n", 0); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	r, _ := newCounterRef(srv, 5)
+	err := r.Transaction(increment, firebase.TxMaxAttempts(3))
+	if !errors.Is(err, firebase.ErrETagMismatch) {
+		t.Fatalf("expected ErrETagMismatch, got: %v", err)
+	}
+
+	if v := string(srv.Data("/n")); v != "30" {
+		t.Errorf("expected 30, got: %s", v)
+	}
+}
+
+func TestTransactionAbort(t *testing.T) {
+	srv := firebasetest.NewServer()
+	defer srv.Close()
+
+	if err := srv.SetData("/n", 0); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	r, _ := newCounterRef(srv, 0)
+	err := r.Transaction(func(json.RawMessage) (interface{}, error) {
+		return nil, firebase.ErrAbort
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if v := string(srv.Data("/n")); v != "0" {
+		t.Errorf("expected 0, got: %s", v)
+	}
+}
@@ -0,0 +1,190 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// UpdateBuilder builds a multi-path update (ie, a fan-out write), that
+// atomically sets the values at multiple paths relative to a Firebase
+// database ref with a single Update.
+//
+// Paths added to the builder must be valid (see IsValidKey), and cannot
+// overlap (ie, a path cannot be the parent of another path), as Firebase
+// rejects such updates. The first invalid path encountered is returned by
+// Apply.
+//
+// The zero value is an empty builder ready to use.
+type UpdateBuilder struct {
+	paths map[string]interface{}
+	err   error
+}
+
+// NewUpdateBuilder creates a new, empty multi-path update builder.
+func NewUpdateBuilder() *UpdateBuilder {
+	return &UpdateBuilder{}
+}
+
+// Set sets the value v at path, relative to the ref the update is applied
+// to. Setting the same path again replaces the previous value.
+func (ub *UpdateBuilder) Set(path string, v interface{}) *UpdateBuilder {
+	if ub.err != nil {
+		return ub
+	}
+
+	key, err := ub.key(path)
+	if err != nil {
+		ub.err = err
+		return ub
+	}
+
+	if ub.paths == nil {
+		ub.paths = make(map[string]interface{})
+	}
+	ub.paths[key] = v
+
+	return ub
+}
+
+// Delete removes the value at path (ie, sets the value to null), relative to
+// the ref the update is applied to.
+func (ub *UpdateBuilder) Delete(path string) *UpdateBuilder {
+	return ub.Set(path, nil)
+}
+
+// key returns the normalized key for path in the update, checking that path
+// is valid and does not overlap with the update's other paths.
+func (ub *UpdateBuilder) key(path string) (string, error) {
+	segs := splitPath(path)
+	if len(segs) == 0 {
+		return "", &Error{Err: "update path cannot be empty"}
+	}
+
+	key := strings.Join(segs, "/")
+	if err := checkPath(key); err != nil {
+		return "", err
+	}
+
+	for k := range ub.paths {
+		if k != key && (strings.HasPrefix(k, key+"/") || strings.HasPrefix(key, k+"/")) {
+			return "", &Error{
+				Err: fmt.Sprintf("update path %s overlaps with path %s", key, k),
+			}
+		}
+	}
+
+	return key, nil
+}
+
+// Len returns the number of paths in the update.
+func (ub *UpdateBuilder) Len() int {
+	return len(ub.paths)
+}
+
+// Map returns a copy of the update's values keyed by path, as passed to
+// Update.
+func (ub *UpdateBuilder) Map() map[string]interface{} {
+	m := make(map[string]interface{}, len(ub.paths))
+	for k, v := range ub.paths {
+		m[k] = v
+	}
+	return m
+}
+
+// Apply applies the update to Firebase database ref r with a single request.
+// No request is made when the update has no paths.
+func (ub *UpdateBuilder) Apply(r *DatabaseRef, opts ...QueryOption) error {
+	return ub.ApplyContext(context.Background(), r, opts...)
+}
+
+// ApplyContext applies the update to Firebase database ref r with a single
+// request, using the provided context.
+func (ub *UpdateBuilder) ApplyContext(ctxt context.Context, r *DatabaseRef, opts ...QueryOption) error {
+	if ub.err != nil {
+		return ub.err
+	}
+	if len(ub.paths) == 0 {
+		return nil
+	}
+
+	return UpdateContext(ctxt, r, ub.paths, opts...)
+}
+
+const (
+	// DefaultRemoveChildrenMaxBytes is the default maximum size of the
+	// payload of each request made by RemoveChildren.
+	DefaultRemoveChildrenMaxBytes = 256 * 1024
+)
+
+// RemoveChildren removes the children keys of Firebase database ref r with a
+// single multi-path update (ie, a PATCH setting each key to null), instead of
+// a request per child. Duplicate keys are removed once, and no request is made
+// when keys is empty.
+//
+// The update is split into multiple requests when its payload would exceed
+// DefaultRemoveChildrenMaxBytes (see RemoveChildrenContext), in which case
+// the removal is no longer atomic.
+func RemoveChildren(r *DatabaseRef, keys []string, opts ...QueryOption) error {
+	return RemoveChildrenContext(context.Background(), r, keys, DefaultRemoveChildrenMaxBytes, opts...)
+}
+
+// RemoveChildrenContext removes the children keys of Firebase database ref r
+// with multi-path updates with payloads of at most maxBytes (or
+// DefaultRemoveChildrenMaxBytes when maxBytes is less than 1), using the
+// provided context.
+//
+// All keys are validated (see IsValidKey) before any request is made. When a
+// request fails, the children removed by the preceding requests remain
+// removed.
+func RemoveChildrenContext(ctxt context.Context, r *DatabaseRef, keys []string, maxBytes int, opts ...QueryOption) error {
+	if maxBytes < 1 {
+		maxBytes = DefaultRemoveChildrenMaxBytes
+	}
+
+	// validate and de-duplicate
+	seen := make(map[string]bool, len(keys))
+	var chunks []map[string]interface{}
+	var chunk map[string]interface{}
+	var size int
+	for _, k := range keys {
+		if err := checkKey(k); err != nil {
+			return err
+		}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+
+		// size of "key":null, in the encoded payload
+		buf, _ := json.Marshal(k)
+		n := len(buf) + 6
+		if chunk == nil || size+n > maxBytes {
+			chunk, size = make(map[string]interface{}), 2
+			chunks = append(chunks, chunk)
+		}
+		chunk[k], size = nil, size+n
+	}
+
+	for _, chunk := range chunks {
+		if err := UpdateContext(ctxt, r, chunk, opts...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RemoveChildren removes the children keys of the Firebase database ref with
+// a single multi-path update.
+func (r *DatabaseRef) RemoveChildren(keys []string, opts ...QueryOption) error {
+	return RemoveChildren(r, keys, opts...)
+}
+
+// RemoveChildrenContext removes the children keys of the Firebase database
+// ref with multi-path updates with payloads of at most maxBytes, using the
+// provided context.
+func (r *DatabaseRef) RemoveChildrenContext(ctxt context.Context, keys []string, maxBytes int, opts ...QueryOption) error {
+	return RemoveChildrenContext(ctxt, r, keys, maxBytes, opts...)
+}
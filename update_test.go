@@ -0,0 +1,115 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestUpdateBuilder(t *testing.T) {
+	var requests int
+	var body map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		if req.Method != "PATCH" {
+			t.Errorf("expected PATCH, got: %s", req.Method)
+		}
+		body = nil
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			t.Errorf("expected no error, got: %v", err)
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	r := newTestRef(t, srv)
+
+	ub := NewUpdateBuilder().
+		Set("/users/a/name", "amy").
+		Set("names/amy/", "a").
+		Delete("users/b").
+		Set("users/a/name", "ann")
+	if err := ub.Apply(r); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	exp := map[string]interface{}{
+		"users/a/name": "ann",
+		"names/amy":    "a",
+		"users/b":      nil,
+	}
+	if !reflect.DeepEqual(body, exp) {
+		t.Errorf("expected %v, got: %v", exp, body)
+	}
+
+	// invalid
+	tests := []*UpdateBuilder{
+		new(UpdateBuilder).Set("users/a", 1).Set("users/a/name", "amy"),
+		new(UpdateBuilder).Set("users/a/name", "amy").Delete("users"),
+		new(UpdateBuilder).Set("users/a.b", 1),
+		new(UpdateBuilder).Set("/", 1),
+	}
+	for i, ub := range tests {
+		if err := ub.Apply(r); err == nil {
+			t.Errorf("test %d expected error", i)
+		}
+	}
+
+	// non-overlapping siblings sharing a prefix
+	if err := new(UpdateBuilder).Set("users/a", 1).Set("users/ab", 2).Apply(r); err != nil {
+		t.Errorf("expected no error, got: %v", err)
+	}
+
+	// empty
+	requests = 0
+	if err := new(UpdateBuilder).Apply(r); err != nil || requests != 0 {
+		t.Errorf("expected no error and no requests, got: %v, %d", err, requests)
+	}
+}
+
+func TestRemoveChildren(t *testing.T) {
+	var bodies []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != "PATCH" {
+			t.Errorf("expected PATCH, got: %s", req.Method)
+		}
+		var body map[string]interface{}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			t.Errorf("expected no error, got: %v", err)
+		}
+		bodies = append(bodies, body)
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	r := newTestRef(t, srv)
+
+	// de-duplicated
+	if err := r.RemoveChildren([]string{"a", "b", "a"}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if exp := []map[string]interface{}{{"a": nil, "b": nil}}; !reflect.DeepEqual(bodies, exp) {
+		t.Errorf("expected %v, got: %v", exp, bodies)
+	}
+
+	// empty
+	bodies = nil
+	if err := r.RemoveChildren(nil); err != nil || len(bodies) != 0 {
+		t.Errorf("expected no requests, got: %d (%v)", len(bodies), err)
+	}
+
+	// invalid key
+	if err := r.RemoveChildren([]string{"a", "b/c"}); err == nil || len(bodies) != 0 {
+		t.Errorf("expected error without requests, got: %v", err)
+	}
+
+	// chunked (two keys per request)
+	if err := r.RemoveChildrenContext(context.Background(), []string{"aa", "bb", "cc"}, 23); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if exp := []map[string]interface{}{{"aa": nil, "bb": nil}, {"cc": nil}}; !reflect.DeepEqual(bodies, exp) {
+		t.Errorf("expected %v, got: %v", exp, bodies)
+	}
+}
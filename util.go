@@ -1,39 +1,372 @@
 package firebase
 
 import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 )
 
+// redactRE matches sensitive query parameters.
+var redactRE = regexp.MustCompile(`([?&](?:auth|access_token)=)[^&\s"]*`)
+
+// redact redacts the values of sensitive query parameters (ie, auth) in s.
+func redact(s string) string {
+	return redactRE.ReplaceAllString(s, "${1}REDACTED")
+}
+
+// redactError redacts the values of sensitive query parameters in the URL of
+// a *url.Error.
+func redactError(err error) error {
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		ue.URL = redact(ue.URL)
+	}
+	return err
+}
+
+// jsonContentType is a query option that sets the Content-Type header of a
+// request to application/json.
+func jsonContentType(q *Query) error {
+	q.Header.Set("Content-Type", "application/json")
+	return nil
+}
+
+// escapePath percent-encodes each segment of path, such that each segment is
+// a single path segment in a request URL.
+func escapePath(path string) string {
+	segs := splitPath(path)
+	for i, s := range segs {
+		segs[i] = url.PathEscape(s)
+	}
+	return "/" + strings.Join(segs, "/")
+}
+
+// gzipBytes returns the gzip compressed buf.
+func gzipBytes(buf []byte) ([]byte, error) {
+	var b bytes.Buffer
+	zw := gzip.NewWriter(&b)
+	if _, err := zw.Write(buf); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// gzipContentEncoding is a query option that sets the Content-Encoding header
+// of a request to gzip.
+func gzipContentEncoding(q *Query) error {
+	q.Header.Set("Content-Encoding", "gzip")
+	return nil
+}
+
+// gzipBody is a gzip decompressing response body.
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+// Close satisfies the io.Closer interface.
+func (gb *gzipBody) Close() error {
+	gb.Reader.Close()
+	return gb.body.Close()
+}
+
+// gunzipBody replaces the body of res with a decompressing reader when the
+// body is gzip encoded.
+func gunzipBody(res *http.Response) error {
+	if !strings.EqualFold(res.Header.Get("Content-Encoding"), "gzip") {
+		return nil
+	}
+
+	zr, err := gzip.NewReader(res.Body)
+	switch {
+	// empty body
+	case err == io.EOF:
+
+	case err != nil:
+		return &Error{
+			Err: fmt.Sprintf("could not decompress body: %v", err),
+			err: err,
+		}
+
+	default:
+		res.Body = &gzipBody{Reader: zr, body: res.Body}
+	}
+
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = -1
+	res.Uncompressed = true
+
+	return nil
+}
+
 // checkServerError looks at a http.Response and determines if it encountered
 // an error, and marshals the error into a Error if it did.
 func checkServerError(res *http.Response) error {
-	// some kind of server error
-	if res.StatusCode < 200 || res.StatusCode > 299 {
+	// decompress body
+	if err := gunzipBody(res); err != nil {
+		return err
+	}
+
+	// not modified (conditional get)
+	if res.StatusCode == http.StatusNotModified {
+		return nil
+	}
+
+	// etag mismatch, the body contains the current value
+	if res.StatusCode == http.StatusPreconditionFailed {
 		buf, err := ioutil.ReadAll(res.Body)
 		if err != nil {
 			return &Error{
 				Err: fmt.Sprintf("unable to read server error: %v", err),
 			}
 		}
-		if len(buf) < 1 {
-			return &Error{
-				Err: fmt.Sprintf("empty server error: %s (%d)", res.Status, res.StatusCode),
-			}
+
+		return &ETagMismatchError{
+			ETag:  res.Header.Get("ETag"),
+			Value: json.RawMessage(buf),
 		}
+	}
 
-		var e Error
-		err = json.Unmarshal(buf, &e)
-		if err != nil {
-			return &Error{
-				Err: fmt.Sprintf("unknown server error: %s (%d)", string(buf), res.StatusCode),
-			}
+	// some kind of server error
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		e := &Error{
+			StatusCode: res.StatusCode,
+		}
+		if res.Request != nil {
+			e.Method = res.Request.Method
+			e.Path = strings.TrimSuffix(res.Request.URL.Path, ".json")
 		}
 
-		return &e
+		buf, err := ioutil.ReadAll(res.Body)
+		switch {
+		case err != nil:
+			e.Err = fmt.Sprintf("unable to read server error: %v", err)
+			e.err = err
+
+		case len(buf) < 1:
+			e.Err = fmt.Sprintf("empty server error: %s", http.StatusText(res.StatusCode))
+
+		case json.Unmarshal(buf, e) != nil || e.Err == "":
+			e.Err = fmt.Sprintf("unknown server error: %s", string(buf))
+
+		default:
+			e.Message = e.Err
+		}
+
+		return e
 	}
 
 	return nil
 }
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// requestID returns a query option that sets the HTTP header to the request
+// id with the attempt number appended.
+func requestID(header, id string, attempt int) QueryOption {
+	return func(q *Query) error {
+		q.Header.Set(header, id+"."+strconv.Itoa(attempt))
+		return nil
+	}
+}
+
+// ErrResponseTooLarge is the error returned when a response body (or a
+// streamed event) exceeds the maximum response size of the database ref (see
+// MaxResponseBytes).
+var ErrResponseTooLarge = &Error{Err: "response too large"}
+
+// ResponseTooLargeError is the error returned when a response body (or a
+// streamed event) exceeds the maximum response size of the database ref. It
+// contains the limit and the number of bytes read prior to the limit being
+// exceeded.
+//
+// ResponseTooLargeError wraps ErrResponseTooLarge, for use with errors.Is.
+type ResponseTooLargeError struct {
+	// Limit is the maximum response size in bytes.
+	Limit int64
+
+	// Read is the number of bytes read when the limit was exceeded.
+	Read int64
+}
+
+// Error satisfies the error interface.
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("%s: read %d bytes, exceeding the limit of %d bytes", ErrResponseTooLarge.Error(), e.Read, e.Limit)
+}
+
+// Unwrap returns ErrResponseTooLarge.
+func (e *ResponseTooLargeError) Unwrap() error {
+	return ErrResponseTooLarge
+}
+
+// limitedBody is a response body that returns a *ResponseTooLargeError once
+// more than limit bytes have been read.
+type limitedBody struct {
+	io.ReadCloser
+	limit, n int64
+	err      *ResponseTooLargeError
+}
+
+// limitBody wraps the body of res with a limited body, when limit is greater
+// than 0.
+func limitBody(res *http.Response, limit int64) *limitedBody {
+	if limit <= 0 {
+		return nil
+	}
+	lb := &limitedBody{ReadCloser: res.Body, limit: limit}
+	res.Body = lb
+	return lb
+}
+
+// Read satisfies the io.Reader interface.
+func (lb *limitedBody) Read(p []byte) (int, error) {
+	if lb.err != nil {
+		return 0, lb.err
+	}
+
+	// read at most one byte past the limit
+	if rem := lb.limit - lb.n + 1; int64(len(p)) > rem {
+		p = p[:rem]
+	}
+	n, err := lb.ReadCloser.Read(p)
+	lb.n += int64(n)
+	if lb.n > lb.limit {
+		lb.err = &ResponseTooLargeError{Limit: lb.limit, Read: lb.n}
+		return n - int(lb.n-lb.limit), lb.err
+	}
+
+	return n, err
+}
+
+// tooLarge returns the error of the limited body, if the limit was exceeded,
+// or err otherwise.
+func (lb *limitedBody) tooLarge(err error) error {
+	if lb != nil && lb.err != nil {
+		return lb.err
+	}
+	return err
+}
+
+// maxPooledBuffer is the maximum capacity of buffers returned to the buffer
+// pool, so that the pool does not retain large buffers.
+const maxPooledBuffer = 64 << 10
+
+// bufferPool is the pool of buffers used for encoding request bodies.
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// getBuffer returns an empty buffer from the buffer pool.
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// putBuffer resets and returns b to the buffer pool.
+func putBuffer(b *bytes.Buffer) {
+	if b.Cap() > maxPooledBuffer {
+		return
+	}
+	b.Reset()
+	bufferPool.Put(b)
+}
+
+// streamBody is a streamed request body that retains the first error
+// encountered reading the underlying reader.
+type streamBody struct {
+	io.Reader
+
+	// op describes the reading of the underlying reader in errors (ie, "read"
+	// or "encode").
+	op string
+
+	// pr is the pipe of an encoded body, and done is closed when its encoder
+	// finishes (see encodeStream).
+	pr   *io.PipeReader
+	done chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+// encodeStream returns a streamed request body that encodes v as JSON
+// through a pipe, using codec when not nil.
+func encodeStream(v interface{}, codec Codec) *streamBody {
+	pr, pw := io.Pipe()
+	sb := &streamBody{Reader: pr, op: "encode", pr: pr, done: make(chan struct{})}
+	go func() {
+		defer close(sb.done)
+		if codec == nil {
+			pw.CloseWithError(json.NewEncoder(pw).Encode(v))
+			return
+		}
+		buf, err := codec.Marshal(v)
+		if err == nil {
+			_, err = pw.Write(buf)
+		}
+		pw.CloseWithError(err)
+	}()
+	return sb
+}
+
+// Read satisfies the io.Reader interface.
+func (sb *streamBody) Read(p []byte) (int, error) {
+	n, err := sb.Reader.Read(p)
+	if err != nil && err != io.EOF {
+		sb.mu.Lock()
+		if sb.err == nil {
+			sb.err = err
+		}
+		sb.mu.Unlock()
+	}
+	return n, err
+}
+
+// Err returns the error encountered reading the body, if any.
+func (sb *streamBody) Err() error {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	if sb.err == nil {
+		return nil
+	}
+	return &Error{
+		Err: fmt.Sprintf("could not %s body: %v", sb.op, sb.err),
+		err: sb.err,
+	}
+}
+
+// close closes the pipe of an encoded body, waiting for the encoder to
+// finish, so that v is no longer in use once the request completes.
+func (sb *streamBody) close() {
+	if sb.pr == nil {
+		return
+	}
+	sb.pr.Close()
+	<-sb.done
+}
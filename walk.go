@@ -0,0 +1,148 @@
+package firebase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// SkipChildren is the error that can be returned by a walk func to skip the
+// children of the visited node. The walk continues with the remaining nodes.
+var SkipChildren = errors.New("skip children")
+
+// WalkFunc is the func called by Walk for each visited node.
+//
+// The path is the path of the node relative to the walked ref (ie, "" for the
+// walked ref itself, and "a/b" for its child "a"'s child "b"). For leaf nodes,
+// raw is the value of the node, or null when no value is stored at the node.
+// For nodes with children, raw is the shallow listing of the node's children
+// (see Shallow).
+type WalkFunc func(path string, leaf bool, raw json.RawMessage) error
+
+// Walk visits the nodes stored at Firebase database ref r, breadth-first and
+// with the children of each node in ascending key order (ie, integer keys
+// first, in numeric order), calling fn for each
+// visited node.
+//
+// The children of each node are discovered with a shallow listing, so that
+// only a single level of values is retrieved per request. As the listing
+// includes the values of primitive children, leaf nodes are not retrieved
+// separately (other than leaves with the value true, which are listed as
+// nodes with children are). Nodes deeper than maxDepth are not visited
+// (ie, 0 visits only r, and -1 visits all nodes).
+//
+// When fn returns SkipChildren, the children of the node are not visited.
+// When fn returns any other error, the walk is stopped and the error is
+// returned.
+func Walk(r *DatabaseRef, maxDepth int, fn WalkFunc) error {
+	return WalkContext(context.Background(), r, maxDepth, fn)
+}
+
+// WalkContext visits the nodes stored at Firebase database ref r, calling fn
+// for each visited node, using the provided context.
+func WalkContext(ctxt context.Context, r *DatabaseRef, maxDepth int, fn WalkFunc) error {
+	if maxDepth < -1 {
+		return &Error{
+			Err: fmt.Sprintf("walk max depth must be -1 or greater, got: %d", maxDepth),
+		}
+	}
+
+	type node struct {
+		path  string
+		depth int
+
+		// raw is the value of a leaf node, when retrieved with its parent's
+		// listing
+		raw json.RawMessage
+	}
+
+	queue := []node{{}}
+	for len(queue) != 0 {
+		n := queue[0]
+		queue = queue[1:]
+
+		raw := n.raw
+		if raw == nil {
+			ref := r
+			if n.path != "" {
+				ref = r.Ref(n.path)
+			}
+
+			var err error
+			if raw, err = GetRawContext(ctxt, ref, Shallow); err != nil {
+				return err
+			}
+		}
+
+		// leaf node
+		if raw[0] != '{' {
+			if err := fn(n.path, true, raw); err != nil && err != SkipChildren {
+				return err
+			}
+			continue
+		}
+
+		err := fn(n.path, false, raw)
+		switch {
+		case err == SkipChildren:
+			continue
+		case err != nil:
+			return err
+		case maxDepth != -1 && n.depth >= maxDepth:
+			continue
+		}
+
+		// decode keys
+		var m map[string]json.RawMessage
+		if err = json.Unmarshal(raw, &m); err != nil {
+			return &Error{
+				Err: fmt.Sprintf("could not unmarshal json: %v", err),
+				err: err,
+			}
+		}
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return keyLess(keys[i], keys[j])
+		})
+
+		for _, k := range keys {
+			c := node{path: k, depth: n.depth + 1}
+			if n.path != "" {
+				c.path = n.path + "/" + k
+			}
+
+			// primitive children are listed with their values (children
+			// with a value of true may also have children)
+			if v := m[k]; !isTrue(v) {
+				c.raw = v
+			}
+
+			queue = append(queue, c)
+		}
+	}
+
+	return nil
+}
+
+// isTrue returns true when buf is the JSON value true.
+func isTrue(buf json.RawMessage) bool {
+	return string(bytes.TrimSpace(buf)) == "true"
+}
+
+// Walk visits the nodes stored at the Firebase database ref, breadth-first,
+// calling fn for each visited node.
+func (r *DatabaseRef) Walk(maxDepth int, fn WalkFunc) error {
+	return Walk(r, maxDepth, fn)
+}
+
+// WalkContext visits the nodes stored at the Firebase database ref, calling fn
+// for each visited node, using the provided context.
+func (r *DatabaseRef) WalkContext(ctxt context.Context, maxDepth int, fn WalkFunc) error {
+	return WalkContext(ctxt, r, maxDepth, fn)
+}
@@ -0,0 +1,106 @@
+package firebase_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/knq/firebase"
+	"github.com/knq/firebase/firebasetest"
+)
+
+func TestWalk(t *testing.T) {
+	srv := firebasetest.NewServer()
+	defer srv.Close()
+
+	if err := srv.SetData("/users", map[string]interface{}{
+		"b": map[string]interface{}{"name": "bob", "tags": map[string]interface{}{"x": true}},
+		"a": map[string]interface{}{"name": "amy"},
+		"c": 3,
+	}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	r := srv.Ref().Ref("/users")
+
+	walk := func(maxDepth int, skip string) ([]string, error) {
+		var paths []string
+		err := r.Walk(maxDepth, func(path string, leaf bool, raw json.RawMessage) error {
+			if leaf {
+				path += "=" + string(raw)
+			}
+			paths = append(paths, path)
+			if skip != "" && path == skip {
+				return firebase.SkipChildren
+			}
+			return nil
+		})
+		return paths, err
+	}
+
+	tests := []struct {
+		maxDepth int
+		skip     string
+		exp      []string
+	}{
+		{-1, "", []string{"", "a", "b", "c=3", `a/name="amy"`, `b/name="bob"`, "b/tags", "b/tags/x=true"}},
+		{0, "", []string{""}},
+		{1, "", []string{"", "a", "b", "c=3"}},
+		{-1, "b", []string{"", "a", "b", "c=3", `a/name="amy"`}},
+	}
+	for i, test := range tests {
+		paths, err := walk(test.maxDepth, test.skip)
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		if !reflect.DeepEqual(paths, test.exp) {
+			t.Errorf("test %d expected %v, got: %v", i, test.exp, paths)
+		}
+	}
+
+	// leaf values are retrieved with their parent's listing (other than
+	// true, which is listed as nodes with children are)
+	d := firebasetest.NewRecordingDoer(srv.Client())
+	if err := srv.Ref(firebase.HTTPDoer(d)).Ref("/users").Walk(-1, func(string, bool, json.RawMessage) error {
+		return nil
+	}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	var requested []string
+	for _, req := range d.Requests() {
+		requested = append(requested, req.URL.Path)
+	}
+	exp := []string{"/users.json", "/users/a.json", "/users/b.json", "/users/b/tags.json", "/users/b/tags/x.json"}
+	if !reflect.DeepEqual(requested, exp) {
+		t.Errorf("expected %v, got: %v", exp, requested)
+	}
+
+	// integer keys
+	if err := srv.SetData("/nums", map[string]interface{}{"10": 1, "9": 2, "a": 3}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	var paths []string
+	err := srv.Ref().Ref("/nums").Walk(-1, func(path string, leaf bool, raw json.RawMessage) error {
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if exp := []string{"", "9", "10", "a"}; !reflect.DeepEqual(paths, exp) {
+		t.Errorf("expected %v, got: %v", exp, paths)
+	}
+
+	// abort
+	errStop := errors.New("stop")
+	var n int
+	err = r.Walk(-1, func(string, bool, json.RawMessage) error {
+		if n++; n == 2 {
+			return errStop
+		}
+		return nil
+	})
+	if err != errStop || n != 2 {
+		t.Errorf("expected %v after 2 nodes, got: %v after %d", errStop, err, n)
+	}
+}